@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/heilkit/tt/tt"
 	"log/slog"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 )
 
@@ -15,8 +21,8 @@ var log = slog.Default()
 
 const MB = 1 << 20
 
-func CmdInfo(url string) {
-	vid, err := tt.GetUserDetail(url)
+func CmdInfo(ctx context.Context, url string) {
+	vid, err := tt.GetUserDetailContext(ctx, url)
 	if err != nil {
 		log.Error(fmt.Sprintf("%s: %s", url, err.Error()))
 	}
@@ -28,8 +34,38 @@ func CmdInfo(url string) {
 	print(string(buffer))
 }
 
-func CmdVideo(url string, sd *bool, json_ *bool, to_ *string, directory *string, retries *int) {
-	post, err := tt.GetPost(url, !*sd)
+// CmdSearchUsers prints up to limit users found by keyword as a table, or as JSON.
+func CmdSearchUsers(ctx context.Context, keyword string, limit int, json_ bool) {
+	users := []tt.SearchedUser{}
+	for user, err := range tt.UserSearchResults(ctx, keyword) {
+		if err != nil {
+			log.Error(fmt.Sprintf("%s: %s", keyword, err.Error()))
+			break
+		}
+		if users = append(users, user); len(users) >= limit {
+			break
+		}
+	}
+
+	if json_ {
+		buffer, err := json.MarshalIndent(users, "", "\t")
+		if err != nil {
+			log.Error(fmt.Sprintf("%s: %s", keyword, err.Error()))
+		}
+		fmt.Println(string(buffer))
+		return
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(table, "UNIQUE ID\tNICKNAME\tFOLLOWERS\tVIDEOS\tVERIFIED")
+	for _, user := range users {
+		_, _ = fmt.Fprintf(table, "%s\t%s\t%d\t%d\t%t\n", user.UniqueId, user.Nickname, user.FollowerCount, user.VideoCount, user.Verified)
+	}
+	_ = table.Flush()
+}
+
+func CmdVideo(ctx context.Context, url string, sd *bool, json_ *bool, to_ *string, opt tt.DownloadOpt) {
+	post, err := tt.GetPostContext(ctx, url, !*sd)
 	if err != nil {
 		log.Error(fmt.Sprintf("%s: %s", url, err.Error()))
 	}
@@ -42,14 +78,15 @@ func CmdVideo(url string, sd *bool, json_ *bool, to_ *string, directory *string,
 		print(string(buffer))
 
 	} else {
-		filename, err := post.Download(&tt.DownloadOpt{
-			Filename:  *to_,
-			Directory: *directory,
-			Retries:   *retries,
-			Fallback:  tt.FallbackToSD,
-			SD:        *sd,
-			Log:       log,
-		})
+		if *to_ != "" {
+			opt.Filename = *to_
+			opt.FilenameFormat = nil
+		}
+		filename, err := post.DownloadContext(ctx, &opt)
+		if errors.Is(err, tt.ErrArchived) {
+			log.Info("Skipped, the post is in the archive", "post", post.ID())
+			return
+		}
 		if err != nil {
 			log.Error(fmt.Sprintf("%s: %s", url, err.Error()))
 		}
@@ -58,21 +95,21 @@ func CmdVideo(url string, sd *bool, json_ *bool, to_ *string, directory *string,
 }
 
 type CmdProfileOpt struct {
-	SD        bool
-	json      bool
-	until     string
-	retries   int
-	maxSize   int64
-	directory string
-	ignore    bool
+	SD         bool
+	json       bool
+	until      string
+	maxSize    int64
+	ignore     bool
+	checkpoint string
+	stream     bool
+	hdWorkers  int
+	jobs       int
+	download   tt.DownloadOpt
 }
 
-func CmdProfile(user string, opt CmdProfileOpt) (err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("%v", r)
-		}
-	}()
+func CmdProfile(ctx context.Context, user string, opt CmdProfileOpt) (err error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
 	log.Info("Starting profile download", "user", user, "HD", !opt.SD)
 
@@ -84,18 +121,70 @@ func CmdProfile(user string, opt CmdProfileOpt) (err error) {
 		log.Info("Ignoring videos before", "time", opt.until)
 	}
 
-	postChan, expectedCount, err := tt.GetUserFeed(user, tt.FeedOpt{
+	var checkpoint tt.CheckpointStore
+	// done confirms the post, so the later scans skip it.
+	done := func(post tt.Post) {}
+	if opt.checkpoint != "" {
+		store := tt.NewFileCheckpointStore(opt.checkpoint)
+		defer func() {
+			if err := store.Flush(); err != nil {
+				log.Error("Could not save the checkpoint", "error", err)
+			}
+		}()
+		checkpoint = store
+		done = func(post tt.Post) {
+			if err := store.Done(user, post.ID()); err != nil {
+				log.Error("Could not save the checkpoint", "post", post.ID(), "error", err)
+			}
+		}
+	}
+
+	feedOpt := tt.FeedOpt{
 		While: tt.WhileAfter(until),
 		OnError: func(err error) {
 			if err != nil {
-				panic(fmt.Errorf("could not get user feed: %w", err))
+				cancel(fmt.Errorf("could not get user feed: %w", err))
 			}
 		},
-		SD:     opt.SD,
-		Filter: func(post *tt.Post) bool { return post.Size < opt.maxSize*MB },
-	})
+		SD: opt.SD,
+		Filter: func(post *tt.Post) bool {
+			return post.Size < opt.maxSize*MB && (opt.download.Archive == nil || !opt.download.Archive.Has(post.ID()))
+		},
+		Checkpoint: checkpoint,
+		HDWorkers:  opt.hdWorkers,
+		KeepOrder:  true,
+	}
+
+	var postChan chan tt.Post
+	var expected func() string
+	if opt.stream {
+		var progress *tt.FeedProgress
+		postChan, progress, err = tt.GetUserFeedStreamContext(ctx, user, feedOpt)
+		expected = func() string {
+			count, final := progress.Expected()
+			if final {
+				return strconv.Itoa(count)
+			}
+			return fmt.Sprintf("%d+", count)
+		}
+	} else {
+		var expectedCount int
+		postChan, expectedCount, err = tt.GetUserFeedContext(ctx, user, feedOpt)
+		expected = func() string { return strconv.Itoa(expectedCount) }
+		log.Info(fmt.Sprintf("Expecting %d posts", expectedCount))
+	}
+	if err != nil {
+		return fmt.Errorf("could not get user feed: %w", err)
+	}
+
+	downloadOpt := &opt.download
+	if opt.jobs > 1 {
+		downloadOpt.Scheduler = tt.NewScheduler(opt.jobs, 0)
+	}
+	jobs := make(chan struct{}, max(opt.jobs, 1))
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
 
-	log.Info(fmt.Sprintf("Expecting %d posts", expectedCount))
 	jsonList := []string{}
 	i := 0
 	for post := range postChan {
@@ -109,21 +198,42 @@ func CmdProfile(user string, opt CmdProfileOpt) (err error) {
 			continue
 		}
 
-		files, err := post.Download(&tt.DownloadOpt{
-			Directory: opt.directory,
-			Retries:   opt.retries,
-			Fallback:  tt.FallbackToSD,
-			SD:        opt.SD,
-			Log:       log,
-		})
-		if err != nil {
-			err := fmt.Errorf("could not download post %s: %w", post.ID(), err)
-			if !opt.ignore {
-				return err
-			}
-			log.Error("While downloading", "post", post.ID(), "err", err)
+		select {
+		case jobs <- struct{}{}:
+		case <-ctx.Done():
+			return context.Cause(ctx)
 		}
-		log.Info(fmt.Sprintf("[%d/%d]\t Downloaded post %s to %s", i, expectedCount, post.ID(), strings.Join(files, ", ")))
+		wg.Add(1)
+		go func(i int, post tt.Post) {
+			defer wg.Done()
+			defer func() { <-jobs }()
+
+			files, err := post.DownloadContext(ctx, downloadOpt)
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, tt.ErrArchived) {
+				done(post)
+				log.Info(fmt.Sprintf("[%d/%s]\t Skipped post %s, it's in the archive", i, expected(), post.ID()))
+				return
+			}
+			if err != nil {
+				err := fmt.Errorf("could not download post %s: %w", post.ID(), err)
+				if !opt.ignore {
+					cancel(err)
+					return
+				}
+				log.Error("While downloading", "post", post.ID(), "err", err)
+				return
+			}
+			done(post)
+			log.Info(fmt.Sprintf("[%d/%s]\t Downloaded post %s to %s", i, expected(), post.ID(), strings.Join(files, ", ")))
+		}(i, post)
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return err
 	}
 
 	if opt.json {
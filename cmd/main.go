@@ -1,20 +1,25 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/heilkit/tt/tt"
 	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
 )
 
 func main() {
 	flag.Usage = func() {
-		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-profile | -info] [args...] <urls | usernames | ids>\n", os.Args[0])
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-profile | -info | -search-users] [args...] <urls | usernames | ids | keywords>\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 	cmdProfile := flag.Bool("profile", false, "download/scan profiles")
 	cmdInfo := flag.Bool("info", false, "print info about profiles")
+	cmdSearchUsers := flag.Bool("search-users", false, "search users by keywords, print them as a table or as json with -json")
+	searchLimit := flag.Int("search-limit", 30, "print up to `N` users found by -search-users")
 	until := flag.String("until", "1970-01-01 00:00:00", "don't download videos earlier than")
 	sd := flag.Bool("sd", false, "don't request HD sources of videos (less requests => notably faster)")
 	directory := flag.String("dir", "./", "directory to save files")
@@ -25,6 +30,28 @@ func main() {
 	debug := flag.Bool("debug", false, "log debug info")
 	quiet_ := flag.Bool("quiet", false, "print only errors")
 	ignore := flag.Bool("ignore", false, "ignore errors and continue downloading")
+	stream := flag.Bool("stream", false, "start downloading profiles from the newest posts, without waiting for the whole feed")
+	hdWorkers := flag.Int("hd-workers", 1, "resolve HD sources of profile posts with `N` parallel requests")
+	jobs := flag.Int("jobs", 1, "download up to `N` profile posts in parallel")
+	output := flag.String("output", "", "filename `TEMPLATE`, i.e. \"{author.unique_id}/{create_time:2006-01}/{id}_{title:.40}.{ext}\"")
+	writeInfoJSON := flag.Bool("write-info-json", false, "write post metadata to <file>.info.json next to the downloads")
+	writeDescription := flag.Bool("write-description", false, "write post title to <file>.description next to the downloads")
+	writeCaption := flag.Bool("write-caption", false, "write a human-readable summary of the post to <file>.txt next to the downloads")
+	include := flag.String("include", "video,images", "download the `ASSETS` of posts: video, images, cover, origin_cover, music, avatar or all")
+	embedMetadata := flag.Bool("embed-metadata", false, "write title, author, date, source URL and cover art into the downloaded videos")
+	ffmpeg := flag.String("ffmpeg", "", "use ffmpeg binary at `PATH` to process videos, instead of the built-in MP4 writer")
+	allowWatermark := flag.Bool("allow-watermark", false, "download watermarked videos when there's no other source, instead of failing")
+	slideshow := flag.Float64("slideshow", 0, "turn photo albums into MP4 slideshows with their music, showing each image for `SECONDS`")
+	pack := flag.String("pack", "", "pack photo albums with their music into a `FORMAT` archive: cbz or zip")
+	sha256 := flag.Bool("sha256", false, "write <file>.sha256 checksums next to the downloads")
+	moveTo := flag.String("move-to", "", "move finished downloads to `DIR`")
+	execCmd := flag.String("exec", "", "run `CMD` for every downloaded file, {} is replaced with the filename, i.e. \"chmod 444 {}\"")
+	archivePath := flag.String("archive", "", "skip posts listed in the download archive `FILE`, and list the downloaded ones there")
+	mirrors := flag.String("mirrors", "", "use tikwm-compatible APIs at comma-separated `URLS` in order, failing ones are skipped for a while")
+	cacheDir := flag.String("cache", "", "cache post and user info responses in `DIR`, so repeated runs don't ask for them again")
+	record := flag.String("record", "", "record API requests and downloads with their responses to `DIR`, secrets are redacted")
+	replay := flag.String("replay", "", "replay a session recorded with -record from `DIR`, without the network")
+	checkpoint := flag.String("checkpoint", "", "resume profile scans from `FILE` and skip posts downloaded by the previous scans")
 	flag.Parse()
 	if *retries == 0 {
 		*retries = -1
@@ -44,29 +71,165 @@ func main() {
 	if *json_ {
 		log = slog.New(slog.NewJSONHandler(os.Stdout, getOptions(*debug, *quiet_)))
 	}
+	tt.DefaultClient.Log = log
+	tt.DefaultClient.Retry = &tt.RetryPolicy{MaxAttempts: max(*retries, 0) + 1}
+
+	if *mirrors != "" {
+		tt.DefaultClient.Mirrors = strings.Split(*mirrors, ",")
+	}
+
+	if *cacheDir != "" {
+		cache, err := tt.NewDiskCache(*cacheDir)
+		if err != nil {
+			log.Error("Could not open the cache", "error", err)
+			os.Exit(1)
+		}
+		tt.DefaultClient.Cache = cache
+	}
+
+	if *record != "" && *replay != "" {
+		log.Error("Use either -record or -replay")
+		os.Exit(1)
+	}
+	if *record != "" || *replay != "" {
+		dir, mode := *record, tt.CassetteRecord
+		if *replay != "" {
+			dir, mode = *replay, tt.CassetteReplay
+		}
+		cassette, err := tt.NewCassette(dir, mode)
+		if err != nil {
+			log.Error("Could not open the cassette", "error", err)
+			os.Exit(1)
+		}
+		tt.DefaultClient.Transport = cassette
+	}
+
+	var filenameFormat func(post *tt.Post, i int) string
+	if *output != "" {
+		var err error
+		if filenameFormat, err = tt.FilenameTemplate(*output); err != nil {
+			log.Error("Bad -output", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	assets, err := tt.ParseAssets(*include)
+	if err != nil {
+		log.Error("Bad -include", "error", err)
+		os.Exit(1)
+	}
+
+	var archive *tt.Archive
+	if *archivePath != "" {
+		var err error
+		if archive, err = tt.OpenArchive(*archivePath); err != nil {
+			log.Error("Could not open the archive", "error", err)
+			os.Exit(1)
+		}
+		defer archive.Close()
+	}
+
+	var embed func(ctx context.Context, post *tt.Post, filename string) error
+	if *embedMetadata {
+		embed = tt.EmbedMetadataMP4
+		if *ffmpeg != "" {
+			embed = tt.EmbedMetadataWithFfmpeg(*ffmpeg)
+		}
+	}
+
+	if *slideshow > 0 && *pack != "" {
+		log.Error("Use either -slideshow or -pack")
+		os.Exit(1)
+	}
+	if *pack != "" && *pack != "cbz" && *pack != "zip" {
+		log.Error("Bad -pack, expected cbz or zip", "pack", *pack)
+		os.Exit(1)
+	}
+
+	execCommand := strings.Fields(*execCmd)
+	if *execCmd != "" && len(execCommand) == 0 {
+		log.Error("Bad -exec, expected a command")
+		os.Exit(1)
+	}
+
+	postProcessors := []tt.PostProcessor{}
+	if *slideshow > 0 {
+		postProcessors = append(postProcessors, tt.Slideshow(&tt.AlbumOpt{SecondsPerImage: *slideshow, Ffmpeg: *ffmpeg}))
+	}
+	if *pack != "" {
+		postProcessors = append(postProcessors, tt.PackAlbum(*pack))
+	}
+	if len(execCommand) != 0 {
+		postProcessors = append(postProcessors, tt.Exec(execCommand[0], execCommand[1:]...))
+	}
+	if *moveTo != "" {
+		postProcessors = append(postProcessors, tt.MoveTo(*moveTo))
+	}
+	if *sha256 {
+		postProcessors = append(postProcessors, tt.HashSHA256())
+	}
+
+	quality := &tt.QualityPolicy{Prefer: []tt.Variant{tt.VariantHD, tt.VariantSD}, AllowWatermark: *allowWatermark}
+	if *sd {
+		quality.Prefer = []tt.Variant{tt.VariantSD}
+	}
+
+	downloadOpt := tt.DownloadOpt{
+		FilenameFormat:   filenameFormat,
+		Directory:        *directory,
+		Retries:          *retries,
+		Fallback:         tt.FallbackToSD,
+		SD:               *sd,
+		WriteInfoJSON:    *writeInfoJSON,
+		WriteDescription: *writeDescription,
+		WriteCaption:     *writeCaption,
+		Quality:          quality,
+		OnDownloaded: func(file tt.DownloadedFile) {
+			if file.Variant != "" {
+				log.Info("Downloaded video", "file", file.Filename, "variant", file.Variant)
+			}
+		},
+		Include:        assets,
+		EmbedMetadata:  embed,
+		PostProcessors: postProcessors,
+		Archive:        archive,
+		Log:            log,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
 	for _, url := range urls {
+		if ctx.Err() != nil {
+			break
+		}
 		ensureDir(*directory)
 
 		switch {
 		case *cmdProfile:
-			if err := CmdProfile(url, CmdProfileOpt{
-				SD:        *sd,
-				json:      *json_,
-				until:     *until,
-				retries:   *retries,
-				maxSize:   *maxSize,
-				directory: *directory,
-				ignore:    *ignore,
+			if err := CmdProfile(ctx, url, CmdProfileOpt{
+				SD:         *sd,
+				json:       *json_,
+				until:      *until,
+				maxSize:    *maxSize,
+				ignore:     *ignore,
+				checkpoint: *checkpoint,
+				stream:     *stream,
+				hdWorkers:  *hdWorkers,
+				jobs:       *jobs,
+				download:   downloadOpt,
 			}); err != nil {
 				log.Error("Downloading profile failed", "user", url, "error", err)
 			}
 
 		case *cmdInfo:
-			CmdInfo(url)
+			CmdInfo(ctx, url)
+
+		case *cmdSearchUsers:
+			CmdSearchUsers(ctx, url, *searchLimit, *json_)
 
 		default:
-			CmdVideo(url, sd, json_, to_, directory, retries)
+			CmdVideo(ctx, url, sd, json_, to_, downloadOpt)
 		}
 
 	}
@@ -1,5 +1,5 @@
 module github.com/heilkit/tt
 
-go 1.22
+go 1.23
 
 require github.com/cavaliergopher/grab/v3 v3.0.1
@@ -0,0 +1,220 @@
+package tt
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxMusicSize of the music to pack.
+const maxMusicSize = 64 << 20
+
+// AlbumOpt of Slideshow and PackAlbum.
+type AlbumOpt struct {
+	// SecondsPerImage of slideshows, default: 3.
+	SecondsPerImage float64
+	// Width and Height of slideshows, images are fit into it, default: 1080x1920.
+	Width  int
+	Height int
+	// Ffmpeg binary, default: "ffmpeg" from PATH.
+	Ffmpeg string
+	// KeepImages of the album, by default they are removed once converted.
+	KeepImages bool
+}
+
+func (opt *AlbumOpt) WithDefaults() *AlbumOpt {
+	if opt == nil {
+		opt = &AlbumOpt{}
+	}
+	if opt.SecondsPerImage <= 0 {
+		opt.SecondsPerImage = 3
+	}
+	if opt.Width <= 0 || opt.Height <= 0 {
+		opt.Width, opt.Height = 1080, 1920
+	}
+	if opt.Ffmpeg == "" {
+		opt.Ffmpeg = "ffmpeg"
+	}
+	return opt
+}
+
+// Slideshow turns photo-mode albums into <file>.mp4 with the music of the post, other posts are left as is.
+// The downloaded music is used if it's there (see AssetMusic), otherwise it's fetched with the client of the download.
+func Slideshow(opt ...*AlbumOpt) PostProcessor {
+	options := AlbumOpt{}
+	if len(opt) != 0 && opt[0] != nil {
+		options = *opt[0]
+	}
+	options.WithDefaults()
+
+	return albumProcessor(options, "mp4", func(ctx context.Context, post *Post, images []string, music string, output string) error {
+		list := strings.Builder{}
+		for _, image := range append(slices.Clone(images), images[len(images)-1]) {
+			absolute, err := filepath.Abs(image)
+			if err != nil {
+				return err
+			}
+			// The last image is repeated for the concat demuxer to respect its duration.
+			_, _ = fmt.Fprintf(&list, "file '%s'\nduration %g\n", strings.ReplaceAll(absolute, "'", `'\''`), options.SecondsPerImage)
+		}
+		listFile := output + ".images.txt"
+		if err := os.WriteFile(listFile, []byte(list.String()), 0644); err != nil {
+			return err
+		}
+		defer os.Remove(listFile)
+
+		if strings.Contains(music, "://") {
+			data, err := clientOf(ctx).fetch(ctx, music, maxMusicSize)
+			if err != nil {
+				return fmt.Errorf("music: %w", err)
+			}
+			music = output + ".music.mp3"
+			if err := os.WriteFile(music, data, 0644); err != nil {
+				return err
+			}
+			defer os.Remove(music)
+		}
+
+		args := []string{"-y", "-loglevel", "error", "-f", "concat", "-safe", "0", "-i", listFile}
+		if music != "" {
+			args = append(args, "-i", music, "-map", "0:v", "-map", "1:a", "-c:a", "aac")
+		}
+		filter := fmt.Sprintf("scale=%[1]d:%[2]d:force_original_aspect_ratio=decrease,pad=%[1]d:%[2]d:(ow-iw)/2:(oh-ih)/2,fps=30,format=yuv420p",
+			options.Width, options.Height)
+		args = append(args, "-vf", filter, "-c:v", "libx264",
+			"-t", fmt.Sprintf("%g", options.SecondsPerImage*float64(len(images))), "-f", "mp4", output)
+
+		out, err := exec.CommandContext(ctx, options.Ffmpeg, args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("err: %s,\n%s", err.Error(), string(out))
+		}
+		return nil
+	})
+}
+
+// PackAlbum packs the images of photo-mode albums into <file>.<extension>, i.e. "cbz" or "zip", other posts are left as is.
+// The images are named 001.jpg, 002.jpg... and the music is music.mp3, it's fetched with the client of the download
+// unless it's downloaded (see AssetMusic).
+func PackAlbum(extension string, opt ...*AlbumOpt) PostProcessor {
+	options := AlbumOpt{}
+	if len(opt) != 0 && opt[0] != nil {
+		options = *opt[0]
+	}
+	options.WithDefaults()
+
+	return albumProcessor(options, extension, func(ctx context.Context, post *Post, images []string, music string, output string) error {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		archive := zip.NewWriter(file)
+		for i, image := range images {
+			if err := addToZip(archive, fmt.Sprintf("%03d%s", i+1, filepath.Ext(image)), image); err != nil {
+				return err
+			}
+		}
+		if music != "" {
+			if err := addMusicToZip(ctx, archive, music); err != nil {
+				return err
+			}
+		}
+		if err := archive.Close(); err != nil {
+			return err
+		}
+		return file.Close()
+	})
+}
+
+func addToZip(archive *zip.Writer, name string, filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Images and music are compressed already.
+	header := &zip.FileHeader{Name: name, Method: zip.Store, Modified: stat.ModTime()}
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+func addMusicToZip(ctx context.Context, archive *zip.Writer, music string) error {
+	if !strings.Contains(music, "://") {
+		return addToZip(archive, "music.mp3", music)
+	}
+
+	data, err := clientOf(ctx).fetch(ctx, music, maxMusicSize)
+	if err != nil {
+		return fmt.Errorf("music: %w", err)
+	}
+	writer, err := archive.CreateHeader(&zip.FileHeader{Name: "music.mp3", Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// albumProcessor finds the images and the music of albums, convert writes output to replace the images with.
+func albumProcessor(opt AlbumOpt, extension string,
+	convert func(ctx context.Context, post *Post, images []string, music string, output string) error) PostProcessor {
+	return func(ctx context.Context, post *Post, filenames []string, log *slog.Logger) ([]string, error) {
+		// The images come first, as they were downloaded.
+		if !post.IsAlbum() || len(filenames) < len(post.Images) || slices.ContainsFunc(filenames[:len(post.Images)],
+			func(filename string) bool { return assetOf(filename) != "" }) {
+			return filenames, nil
+		}
+		images, rest := filenames[:len(post.Images)], filenames[len(post.Images):]
+
+		music := post.musicURL()
+		for _, filename := range rest {
+			if assetOf(filename) == AssetMusic {
+				music = filename
+			}
+		}
+
+		output := albumFilename(images[0]) + "." + extension
+		tmp := filepath.Join(filepath.Dir(output), ".tmp."+filepath.Base(output))
+		defer os.Remove(tmp)
+		if err := convert(ctx, post, images, music, tmp); err != nil {
+			return nil, fmt.Errorf("album to %s: %w", extension, err)
+		}
+		if err := os.Rename(tmp, output); err != nil {
+			return nil, fmt.Errorf("album to %s: %w", extension, err)
+		}
+		log.Debug("Converted album", "file", output, "images", len(images))
+
+		if !opt.KeepImages {
+			for _, image := range images {
+				if err := os.Remove(image); err != nil {
+					log.Warn("Could not remove the album image", "file", image, "err", err)
+				}
+			}
+			images = nil
+		}
+		return append(append([]string{output}, images...), rest...), nil
+	}
+}
+
+// albumFilename is the first image without the extension and the index, i.e. "user_2022-12-21_id" for "user_2022-12-21_id_1.jpg".
+func albumFilename(image string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(image, filepath.Ext(image)), "_1")
+}
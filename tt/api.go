@@ -1,32 +1,56 @@
 package tt
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"strconv"
-	"sync"
 	"time"
 )
 
+// Package-level defaults, Timeout between requests is read once, on the first request without Client.Limiter.
 var (
 	URL              string        = "https://tikwm.com/api"
 	Timeout          time.Duration = time.Second + time.Millisecond*100
 	MaxUserFeedCount int           = 33
 	Debug                          = false
-	requestSync      *sync.Mutex   = &sync.Mutex{}
 )
 
 func Raw(method string, query map[string]string) ([]byte, error) {
-	if Timeout != 0 {
-		requestSync.Lock()
-		defer unlock()
+	return DefaultClient.RawContext(context.Background(), method, query)
+}
+
+func RawContext(ctx context.Context, method string, query map[string]string) ([]byte, error) {
+	return DefaultClient.RawContext(ctx, method, query)
+}
+
+func (c *Client) Raw(method string, query map[string]string) ([]byte, error) {
+	return c.RawContext(context.Background(), method, query)
+}
+
+// RawContext retries transient failures according to Client.Retry, the retries go to the next of Client.Mirrors.
+func (c *Client) RawContext(ctx context.Context, method string, query map[string]string) (data []byte, err error) {
+	endpoint := c.endpointRotation("")
+	err = c.retry(ctx, method, func() error {
+		base, start := endpoint(), time.Now()
+		data, err = c.rawAt(ctx, base, method, query)
+		c.report(err)
+		c.health().Report(base, time.Since(start), err)
+		return err
+	})
+	return data, err
+}
+
+// rawAt requests the method of the API at base.
+func (c *Client) rawAt(ctx context.Context, base string, method string, query map[string]string) ([]byte, error) {
+	if err := c.limiter().Wait(ctx); err != nil {
+		return nil, err
 	}
 
-	url := fmt.Sprintf("%s/%s", URL, method)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	url := fmt.Sprintf("%s/%s", base, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +61,7 @@ func Raw(method string, query map[string]string) ([]byte, error) {
 	}
 	req.URL.RawQuery = q.Encode()
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -48,63 +72,192 @@ func Raw(method string, query map[string]string) ([]byte, error) {
 		return nil, err
 	}
 
-	if Debug {
-		log.Print(string(buffer))
+	if c.debug() {
+		c.log().Info("tikwm response", "method", method, "body", string(buffer))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Method: method, Body: bodySnippet(buffer)}
 	}
 
 	return buffer, nil
 }
 
 func RawParsed[T any](method string, query map[string]string) (*T, error) {
-	data, err := Raw(method, query)
-	if err != nil {
-		return nil, err
+	return rawParsed[T](context.Background(), DefaultClient, method, query)
+}
+
+func RawParsedContext[T any](ctx context.Context, method string, query map[string]string) (*T, error) {
+	return rawParsed[T](ctx, DefaultClient, method, query)
+}
+
+// RawParsed unmarshals the "data" field of the response into v, generic RawParsed is a shortcut for it,
+// that returns ErrNoData instead of a nil result.
+// A non-zero tikwm code is returned as *APIError, a body that isn't JSON as *HTTPError,
+// transient failures are retried according to Client.Retry, on the next of Client.Mirrors.
+// Cached responses skip the rate limit, see Client.Cache.
+func (c *Client) RawParsed(method string, query map[string]string, v any) error {
+	return c.RawParsedContext(context.Background(), method, query, v)
+}
+
+func (c *Client) RawParsedContext(ctx context.Context, method string, query map[string]string, v any) error {
+	return c.rawParsedAt(ctx, "", method, query, v)
+}
+
+// rawParsedAt requests the API at base, or at Client.Mirrors if it's empty.
+func (c *Client) rawParsedAt(ctx context.Context, base string, method string, query map[string]string, v any) error {
+	key := cacheKey(method, query)
+	if data, ok := c.cached(method, key); ok && parse(data, method, query, v) == nil {
+		return nil
 	}
 
+	endpoint := c.endpointRotation(base)
+	return c.retry(ctx, method, func() error {
+		base, start := endpoint(), time.Now()
+		data, err := c.rawAt(ctx, base, method, query)
+		if err == nil {
+			err = parse(data, method, query, v)
+		}
+		c.report(err)
+		c.health().Report(base, time.Since(start), err)
+		if err == nil {
+			c.store(method, key, data)
+		}
+		return err
+	})
+}
+
+func parse(data []byte, method string, query map[string]string, v any) error {
 	var resp struct {
-		Code          int     `json:"code"`
-		Msg           string  `json:"msg"`
-		ProcessedTime float64 `json:"processed_time"`
-		Data          *T      `json:"data"`
+		Code          int             `json:"code"`
+		Msg           string          `json:"msg"`
+		ProcessedTime float64         `json:"processed_time"`
+		Data          json.RawMessage `json:"data"`
 	}
 	if err := json.Unmarshal(data, &resp); err != nil {
-		return nil, err
+		return &HTTPError{StatusCode: http.StatusOK, Method: method, Body: bodySnippet(data), Err: err}
 	}
 	if resp.Code != 0 {
-		queryStr := "???"
-		if buf, err := json.Marshal(query); err == nil {
-			queryStr = string(buf)
-		}
-		return nil, fmt.Errorf("tikwm error: %s (%d) [%s, query: %s]", resp.Msg, resp.Code, method, queryStr)
+		return &APIError{Code: resp.Code, Msg: resp.Msg, Method: method, Query: query, ProcessedTime: resp.ProcessedTime}
+	}
+	if len(resp.Data) == 0 {
+		return nil
 	}
 
-	return resp.Data, nil
+	if err := json.Unmarshal(resp.Data, v); err != nil {
+		return &HTTPError{StatusCode: http.StatusOK, Method: method, Body: bodySnippet(resp.Data), Err: fmt.Errorf("%w: %w", ErrUnexpectedResponse, err)}
+	}
+	return nil
+}
+
+func rawParsed[T any](ctx context.Context, c *Client, method string, query map[string]string) (*T, error) {
+	return rawParsedAt[T](ctx, c, "", method, query)
+}
+
+func rawParsedAt[T any](ctx context.Context, c *Client, base string, method string, query map[string]string) (*T, error) {
+	var data *T
+	if err := c.rawParsedAt(ctx, base, method, query, &data); err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("tikwm [%s]: %w", method, ErrNoData)
+	}
+	return data, nil
 }
 
 // GetPost (hd default: true)
 func GetPost(url string, hd ...bool) (*Post, error) {
-	query := map[string]string{"url": url}
-	if len(hd) == 0 || hd[0] {
-		query["hd"] = "1"
-	}
-	return RawParsed[Post]("", query)
+	return DefaultClient.GetPostContext(context.Background(), url, hd...)
+}
+
+func GetPostContext(ctx context.Context, url string, hd ...bool) (*Post, error) {
+	return DefaultClient.GetPostContext(ctx, url, hd...)
+}
+
+// GetPost (hd default: true)
+func (c *Client) GetPost(url string, hd ...bool) (*Post, error) {
+	return c.GetPostContext(context.Background(), url, hd...)
+}
+
+func (c *Client) GetPostContext(ctx context.Context, url string, hd ...bool) (*Post, error) {
+	withHD := len(hd) == 0 || hd[0]
+	return failover(ctx, c, func(backend Backend) (*Post, error) { return backend.Post(ctx, url, withHD) })
 }
 
 // GetUserFeedRaw is almost unuseful by itself, check wrappers around it -- GetUserFeed/GetUserFeedAwait.
 func GetUserFeedRaw(uniqueID string, count int, cursor string) (*UserFeed, error) {
-	query := map[string]string{"unique_id": uniqueID, "count": strconv.Itoa(count), "cursor": cursor}
-	if _, err := strconv.ParseInt(uniqueID, 10, 64); err == nil {
-		query = map[string]string{"user_id": uniqueID, "count": strconv.Itoa(count), "cursor": cursor}
-	}
-	return RawParsed[UserFeed]("user/posts", query)
+	return DefaultClient.GetUserFeedRawContext(context.Background(), uniqueID, count, cursor)
+}
+
+func GetUserFeedRawContext(ctx context.Context, uniqueID string, count int, cursor string) (*UserFeed, error) {
+	return DefaultClient.GetUserFeedRawContext(ctx, uniqueID, count, cursor)
+}
+
+// GetUserFeedRaw is almost unuseful by itself, check wrappers around it -- GetUserFeed/GetUserFeedAwait.
+func (c *Client) GetUserFeedRaw(uniqueID string, count int, cursor string) (*UserFeed, error) {
+	return c.GetUserFeedRawContext(context.Background(), uniqueID, count, cursor)
+}
+
+func (c *Client) GetUserFeedRawContext(ctx context.Context, uniqueID string, count int, cursor string) (*UserFeed, error) {
+	return failover(ctx, c, func(backend Backend) (*UserFeed, error) { return backend.UserFeed(ctx, uniqueID, count, cursor) })
 }
 
 func GetUserDetail(uniqueID string) (*UserDetail, error) {
-	query := map[string]string{"unique_id": uniqueID}
-	return RawParsed[UserDetail]("user/info", query)
+	return DefaultClient.GetUserDetailContext(context.Background(), uniqueID)
+}
+
+func GetUserDetailContext(ctx context.Context, uniqueID string) (*UserDetail, error) {
+	return DefaultClient.GetUserDetailContext(ctx, uniqueID)
 }
 
-func unlock() {
-	time.Sleep(Timeout)
-	requestSync.Unlock()
+func (c *Client) GetUserDetail(uniqueID string) (*UserDetail, error) {
+	return c.GetUserDetailContext(context.Background(), uniqueID)
+}
+
+func (c *Client) GetUserDetailContext(ctx context.Context, uniqueID string) (*UserDetail, error) {
+	return failover(ctx, c, func(backend Backend) (*UserDetail, error) { return backend.UserDetail(ctx, uniqueID) })
+}
+
+// SearchUsers by keyword, a page at a time. The cursor of the first page is "", check wrappers around it -- UserSearchResults.
+func SearchUsers(keyword string, cursor string) (*UserSearch, error) {
+	return DefaultClient.SearchUsersContext(context.Background(), keyword, cursor)
+}
+
+func SearchUsersContext(ctx context.Context, keyword string, cursor string) (*UserSearch, error) {
+	return DefaultClient.SearchUsersContext(ctx, keyword, cursor)
+}
+
+// SearchUsers by keyword, a page at a time. The cursor of the first page is "", check wrappers around it -- UserSearchResults.
+func (c *Client) SearchUsers(keyword string, cursor string) (*UserSearch, error) {
+	return c.SearchUsersContext(context.Background(), keyword, cursor)
+}
+
+func (c *Client) SearchUsersContext(ctx context.Context, keyword string, cursor string) (*UserSearch, error) {
+	return failover(ctx, c, func(backend Backend) (*UserSearch, error) {
+		searcher, ok := backend.(UserSearcher)
+		if !ok {
+			return nil, fmt.Errorf("backend %T doesn't search users: %w", backend, errors.ErrUnsupported)
+		}
+		return searcher.SearchUsers(ctx, keyword, c.maxUserFeedCount(), cursor)
+	})
+}
+
+// report API responses to the rate limiter, failed requests are ignored.
+func (c *Client) report(err error) {
+	var apiErr *APIError
+	var httpErr *HTTPError
+	if err == nil || errors.As(err, &apiErr) || errors.As(err, &httpErr) {
+		c.limiter().Report(errors.Is(err, ErrRateLimited))
+	}
+}
+
+// sleepContext is time.Sleep, that returns ctx.Err() as soon as ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
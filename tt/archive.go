@@ -0,0 +1,71 @@
+package tt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrArchived is returned by Post.Download for posts that are listed in DownloadOpt.Archive already.
+var ErrArchived = errors.New("post is in the download archive already")
+
+// archiveExtractor is the yt-dlp extractor key, so archives could be shared with it.
+const archiveExtractor = "tiktok"
+
+// Archive is a yt-dlp style download archive: an append-only file of "tiktok <post id>" lines.
+// It's safe to share between concurrent downloads.
+type Archive struct {
+	mu   sync.Mutex
+	ids  map[string]bool
+	file *os.File
+}
+
+// OpenArchive reads the archive at path, creating the file if it doesn't exist.
+func OpenArchive(path string) (*Archive, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+
+	archive := &Archive{ids: map[string]bool{}, file: file}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		extractor, id, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if ok && extractor == archiveExtractor {
+			archive.ids[id] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("archive %s: %w", path, err)
+	}
+	return archive, nil
+}
+
+func (archive *Archive) Has(id string) bool {
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+	return archive.ids[id]
+}
+
+// Add the post id to the archive, adding it twice does nothing.
+func (archive *Archive) Add(id string) error {
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+
+	if archive.ids[id] {
+		return nil
+	}
+	if _, err := fmt.Fprintf(archive.file, "%s %s\n", archiveExtractor, id); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	archive.ids[id] = true
+	return nil
+}
+
+func (archive *Archive) Close() error {
+	return archive.file.Close()
+}
@@ -0,0 +1,68 @@
+package tt_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heilkit/tt/tt"
+)
+
+func TestArchiveOffline(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	path := filepath.Join(t.TempDir(), "archive.txt")
+	// An archive of yt-dlp, with other extractors.
+	if err := os.WriteFile(path, []byte("youtube dQw4w9WgXcQ\ntiktok 7301000000000000004\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := tt.OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	if !archive.Has("7301000000000000004") || archive.Has("dQw4w9WgXcQ") {
+		t.Error("the yt-dlp archive is misread")
+	}
+
+	opt := &tt.DownloadOpt{Directory: t.TempDir(), Timeout: time.Nanosecond, Retries: 1, Archive: archive, Fallback: tt.FallbackToSD}
+	if _, err := client.DownloadPost(server.Post("7301000000000000004"), opt); !errors.Is(err, tt.ErrArchived) {
+		t.Errorf("got %v, want ErrArchived", err)
+	}
+
+	// The HD source is gone, so the post is downloaded again in SD.
+	post := server.Post("7301000000000000001")
+	server.RemoveFile(post.Hdplay)
+	if _, err := client.DownloadPost(post, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := archive.Add(fmt.Sprintf("73020000000000000%02d", i%5)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if count := strings.Count(string(data), "tiktok 7301000000000000001\n"); count != 1 {
+		t.Errorf("the post is listed %d times, want once", count)
+	}
+	if len(lines) != 3+1+5 {
+		t.Errorf("got %d lines, want 9:\n%s", len(lines), data)
+	}
+}
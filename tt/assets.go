@@ -0,0 +1,130 @@
+package tt
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Asset of a post to download, see DownloadOpt.Include.
+type Asset string
+
+const (
+	// AssetVideo is the video itself, for video posts.
+	AssetVideo Asset = "video"
+	// AssetImages are the images of photo-mode albums.
+	AssetImages Asset = "images"
+	// AssetCover saved as <file>_cover.jpg.
+	AssetCover Asset = "cover"
+	// AssetOriginCover saved as <file>_origin_cover.jpg.
+	AssetOriginCover Asset = "origin_cover"
+	// AssetMusic saved as <file>_music.mp3, it's the audio track of photo-mode albums.
+	AssetMusic Asset = "music"
+	// AssetAvatar of the author saved as <file>_avatar.jpg.
+	AssetAvatar Asset = "avatar"
+)
+
+// DefaultAssets are the content of the post: the video, or the images of an album.
+var DefaultAssets = []Asset{AssetVideo, AssetImages}
+
+// AllAssets of a post.
+var AllAssets = []Asset{AssetVideo, AssetImages, AssetCover, AssetOriginCover, AssetMusic, AssetAvatar}
+
+// ParseAssets parses a comma separated list, i.e. "video,images,music", "all" stands for AllAssets.
+func ParseAssets(list string) ([]Asset, error) {
+	assets := []Asset{}
+	for _, name := range strings.Split(list, ",") {
+		asset := Asset(strings.TrimSpace(name))
+		switch {
+		case asset == "all":
+			assets = append(assets, AllAssets...)
+		case slices.Contains(AllAssets, asset):
+			assets = append(assets, asset)
+		default:
+			return nil, fmt.Errorf("unknown asset %q, expected one of %v", asset, AllAssets)
+		}
+	}
+	return assets, nil
+}
+
+// assets to download from the post, the content files come first. The video source is chosen by Quality.
+func (opts *DownloadOpt) assets(post *Post) (files []DownloadedFile, err error) {
+	include := opts.Include
+	if include == nil {
+		include = DefaultAssets
+	}
+
+	if post.IsVideo() && slices.Contains(include, AssetVideo) {
+		url, variant, err := opts.Quality.Choose(post)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, DownloadedFile{path.Join(opts.Directory, opts.FilenameFormat(post, 0)), url, AssetVideo, variant})
+	}
+	if post.IsAlbum() && slices.Contains(include, AssetImages) {
+		for i, url := range post.Images {
+			files = append(files, DownloadedFile{opts.imageFilename(post, i), url, AssetImages, ""})
+		}
+	}
+
+	base := opts.baseFilename(post)
+	extras := map[Asset]string{
+		AssetCover:       post.Cover,
+		AssetOriginCover: post.OriginCover,
+		AssetMusic:       post.musicURL(),
+		AssetAvatar:      post.Author.Avatar,
+	}
+	for _, asset := range AllAssets {
+		if url := extras[asset]; url != "" && slices.Contains(include, asset) {
+			files = append(files, DownloadedFile{base + assetSuffixes[asset], url, asset, ""})
+		}
+	}
+	return files, nil
+}
+
+// assetSuffixes of the files besides the content.
+var assetSuffixes = map[Asset]string{
+	AssetCover:       "_cover.jpg",
+	AssetOriginCover: "_origin_cover.jpg",
+	AssetMusic:       "_music.mp3",
+	AssetAvatar:      "_avatar.jpg",
+}
+
+// assetOf the file, "" for the content.
+func assetOf(filename string) Asset {
+	// origin_cover goes before cover, which is its suffix.
+	for _, asset := range []Asset{AssetOriginCover, AssetCover, AssetMusic, AssetAvatar} {
+		if strings.HasSuffix(filename, assetSuffixes[asset]) {
+			return asset
+		}
+	}
+	return ""
+}
+
+// imageFilename of the i-th image of an album.
+func (opts *DownloadOpt) imageFilename(post *Post, i int) string {
+	filename := path.Join(opts.Directory, opts.FilenameFormat(post, i))
+	// Formats without the index, i.e. "{id}.{ext}" or DownloadTo, would give every image the same name.
+	if len(post.Images) > 1 && opts.FilenameFormat(post, 0) == opts.FilenameFormat(post, 1) {
+		filename = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(filename, path.Ext(filename)), i+1, path.Ext(filename))
+	}
+	return filename
+}
+
+// baseFilename is the content file without its extension, other assets and sidecars are named after it.
+// Albums drop the index of the first image, like PackAlbum and Slideshow do.
+func (opts *DownloadOpt) baseFilename(post *Post) string {
+	if post.IsAlbum() {
+		return albumFilename(opts.imageFilename(post, 0))
+	}
+	filename := path.Join(opts.Directory, opts.FilenameFormat(post, 0))
+	return strings.TrimSuffix(filename, path.Ext(filename))
+}
+
+func (post Post) musicURL() string {
+	if post.MusicInfo.Play != "" {
+		return post.MusicInfo.Play
+	}
+	return post.Music
+}
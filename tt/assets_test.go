@@ -0,0 +1,47 @@
+package tt_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/heilkit/tt/tt"
+)
+
+func TestAssetsOffline(t *testing.T) {
+	if assets, err := tt.ParseAssets("video, music"); err != nil || !slices.Equal(assets, []tt.Asset{tt.AssetVideo, tt.AssetMusic}) {
+		t.Errorf("got %v, %v", assets, err)
+	}
+	if assets, err := tt.ParseAssets("all"); err != nil || !slices.Equal(assets, tt.AllAssets) {
+		t.Errorf("got %v, %v", assets, err)
+	}
+	if _, err := tt.ParseAssets("cover,subtitles"); err == nil {
+		t.Error("an unknown asset is accepted")
+	}
+
+	server := newServer(t)
+	client := server.Client()
+	include := []tt.Asset{tt.AssetVideo, tt.AssetImages, tt.AssetCover, tt.AssetMusic, tt.AssetAvatar}
+	for id, want := range map[string][]string{
+		"7301000000000000004": {".mp4", "_cover.jpg", "_music.mp3", "_avatar.jpg"},
+		// The album is packed, the other assets are named after it rather than after its first image.
+		"7301000000000000003": {".zip", "_cover.jpg", "_music.mp3", "_avatar.jpg"},
+	} {
+		post := server.Post(id)
+		opt := &tt.DownloadOpt{Directory: t.TempDir(), Timeout: time.Nanosecond, Include: include, PostProcessors: []tt.PostProcessor{tt.PackAlbum("zip")}}
+		filenames, err := client.DownloadPost(post, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		base := fmt.Sprintf("losertron_%s_%s", time.Unix(post.CreateTime, 0).Format(time.DateOnly), id)
+		for i, filename := range filenames {
+			if _, err := os.Stat(filename); err != nil || i >= len(want) || filepath.Base(filename) != base+want[i] {
+				t.Errorf("got %v, want %s with %v: %v", filenames, base, want, err)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package tt
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// Backend looks posts and users up, see Client.Backends. Tikwm is the default one.
+type Backend interface {
+	Post(ctx context.Context, url string, hd bool) (*Post, error)
+	UserFeed(ctx context.Context, uniqueID string, count int, cursor string) (*UserFeed, error)
+	UserDetail(ctx context.Context, uniqueID string) (*UserDetail, error)
+}
+
+// Tikwm is the Backend of a tikwm-compatible API. It uses the rate limit, retries and cache of its Client.
+type Tikwm struct {
+	// URL of the API, defaults to Client.Mirrors or Client.URL.
+	URL string
+	// Client defaults to DefaultClient.
+	Client *Client
+}
+
+// UserSearcher is a Backend that searches users, SearchUsers skips the backends that aren't.
+type UserSearcher interface {
+	SearchUsers(ctx context.Context, keyword string, count int, cursor string) (*UserSearch, error)
+}
+
+var _ Backend = &Tikwm{}
+var _ UserSearcher = &Tikwm{}
+
+// NewTikwm is a Backend of the API at url, that shares everything else with client.
+func NewTikwm(client *Client, url string) *Tikwm {
+	return &Tikwm{URL: url, Client: client}
+}
+
+func (backend *Tikwm) client() *Client {
+	if backend.Client != nil {
+		return backend.Client
+	}
+	return DefaultClient
+}
+
+func (backend *Tikwm) Post(ctx context.Context, url string, hd bool) (*Post, error) {
+	query := map[string]string{"url": url}
+	if hd {
+		query["hd"] = "1"
+	}
+	return rawParsedAt[Post](ctx, backend.client(), backend.URL, "", query)
+}
+
+func (backend *Tikwm) UserFeed(ctx context.Context, uniqueID string, count int, cursor string) (*UserFeed, error) {
+	query := map[string]string{"unique_id": uniqueID, "count": strconv.Itoa(count), "cursor": cursor}
+	if _, err := strconv.ParseInt(uniqueID, 10, 64); err == nil {
+		query = map[string]string{"user_id": uniqueID, "count": strconv.Itoa(count), "cursor": cursor}
+	}
+	return rawParsedAt[UserFeed](ctx, backend.client(), backend.URL, "user/posts", query)
+}
+
+func (backend *Tikwm) UserDetail(ctx context.Context, uniqueID string) (*UserDetail, error) {
+	query := map[string]string{"unique_id": uniqueID}
+	return rawParsedAt[UserDetail](ctx, backend.client(), backend.URL, "user/info", query)
+}
+
+func (backend *Tikwm) SearchUsers(ctx context.Context, keyword string, count int, cursor string) (*UserSearch, error) {
+	query := map[string]string{"keywords": keyword, "count": strconv.Itoa(count), "cursor": cursor}
+	return rawParsedAt[UserSearch](ctx, backend.client(), backend.URL, "user/search", query)
+}
+
+func (c *Client) backends() []Backend {
+	if len(c.Backends) != 0 {
+		return c.Backends
+	}
+	return []Backend{&Tikwm{Client: c}}
+}
+
+// failover calls the backends in order until one of them succeeds. Missing, private posts and bad URLs
+// aren't passed on, neither is a done ctx, the other errors are logged and the last one is returned.
+func failover[T any](ctx context.Context, c *Client, call func(backend Backend) (*T, error)) (*T, error) {
+	backends := c.backends()
+	var err error
+	for i, backend := range backends {
+		var result *T
+		if result, err = call(backend); err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil || !failsOver(err) || i == len(backends)-1 {
+			break
+		}
+		c.log().Warn("backend failed, trying the next one", "backend", i, "err", err)
+	}
+	return nil, err
+}
+
+// failsOver tells whether another backend could answer differently.
+func failsOver(err error) bool {
+	return !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrPrivate) && !errors.Is(err, ErrBadURL) &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,39 @@
+package tt_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/heilkit/tt/tt"
+	"github.com/heilkit/tt/tt/tttest"
+)
+
+func TestBackendFailover(t *testing.T) {
+	primary, mirror := newServer(t), newServer(t)
+	client := mirror.Client()
+	client.Backends = []tt.Backend{tt.NewTikwm(client, primary.URL+"/api"), tt.NewTikwm(client, "")}
+
+	// A missing post isn't looked up on the mirror.
+	if _, err := client.GetPost("https://www.tiktok.com/@losertron/video/7301999999999999999"); !errors.Is(err, tt.ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	if hits := mirror.Hits(tttest.MethodPost); hits != 0 {
+		t.Errorf("got %d requests to the mirror, want 0", hits)
+	}
+
+	// The primary backend goes down.
+	primary.Close()
+	post, err := client.GetPost("https://www.tiktok.com/@losertron/video/7301000000000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.ID() != "7301000000000000001" {
+		t.Errorf("got post %s", post.ID())
+	}
+	if hits := mirror.Hits(tttest.MethodPost); hits != 1 {
+		t.Errorf("got %d requests to the mirror, want 1", hits)
+	}
+	if _, err := client.GetUserDetail("losertron"); err != nil {
+		t.Error(err)
+	}
+}
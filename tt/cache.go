@@ -0,0 +1,207 @@
+package tt
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache keeps successful API responses of a Client, see Client.Cache. It's used concurrently.
+type Cache interface {
+	// Get returns the response unless it's missing or expired.
+	Get(key string) (data []byte, ok bool)
+	// Set the response until expires.
+	Set(key string, data []byte, expires time.Time)
+}
+
+// DefaultCacheTTL is how long responses of the methods are cached, the methods that aren't listed are never cached.
+var DefaultCacheTTL = map[string]time.Duration{
+	"":          time.Minute * 10,
+	"user/info": time.Hour,
+}
+
+// expiryParam finds the expiry of signed CDN URLs, "&" is escaped in some JSON encoders.
+var expiryParam = regexp.MustCompile(`(?:[?&]|\\u0026)(?:x-expires|x-expire|expires|expire)=(\d{9,11})\b`)
+
+// expirySafety is subtracted from the expiry of signed URLs, so they aren't handed out right before expiring.
+const expirySafety = time.Minute
+
+// cacheKey is the method with the sorted query, without empty values.
+func cacheKey(method string, query map[string]string) string {
+	values := url.Values{}
+	for key, value := range query {
+		if value = strings.TrimSpace(value); value != "" {
+			values.Set(key, value)
+		}
+	}
+	return method + "?" + values.Encode()
+}
+
+// cached response of the method, if it's cached at all.
+func (c *Client) cached(method string, key string) ([]byte, bool) {
+	if c.Cache == nil {
+		return nil, false
+	}
+	if _, ok := c.cacheTTL(method); !ok {
+		return nil, false
+	}
+	return c.Cache.Get(key)
+}
+
+// store the response until TTL of the method, or until the first of signed URLs in it expires.
+// Responses without data aren't stored, tikwm answers so once in a while.
+func (c *Client) store(method string, key string, data []byte) {
+	if c.Cache == nil || !hasData(data) {
+		return
+	}
+	ttl, ok := c.cacheTTL(method)
+	if !ok {
+		return
+	}
+
+	expires := time.Now().Add(ttl)
+	for _, match := range expiryParam.FindAllSubmatch(data, -1) {
+		if unix, err := strconv.ParseInt(string(match[1]), 10, 64); err == nil {
+			if signed := time.Unix(unix, 0).Add(-expirySafety); signed.Before(expires) {
+				expires = signed
+			}
+		}
+	}
+	if expires.After(time.Now()) {
+		c.Cache.Set(key, data, expires)
+	}
+}
+
+func hasData(data []byte) bool {
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	return json.Unmarshal(data, &resp) == nil && len(resp.Data) != 0 && string(resp.Data) != "null"
+}
+
+func (c *Client) cacheTTL(method string) (time.Duration, bool) {
+	ttls := c.CacheTTL
+	if ttls == nil {
+		ttls = DefaultCacheTTL
+	}
+	ttl, ok := ttls[method]
+	return ttl, ok && ttl > 0
+}
+
+// MemoryCache is an in-memory LRU Cache.
+type MemoryCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+var _ Cache = &MemoryCache{}
+
+// NewMemoryCache keeps up to size responses, evicting the least recently used ones.
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{size: max(size, 1), order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (cache *MemoryCache) Get(key string) ([]byte, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*memoryEntry)
+	if time.Now().After(entry.expires) {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	return entry.data, true
+}
+
+func (cache *MemoryCache) Set(key string, data []byte, expires time.Time) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		element.Value = &memoryEntry{key, data, expires}
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(&memoryEntry{key, data, expires})
+	for cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+// DiskCache keeps responses as files in a directory, so they survive restarts. Expired files are removed once read.
+type DiskCache struct {
+	Dir string
+}
+
+var _ Cache = &DiskCache{}
+
+type diskEntry struct {
+	Key     string          `json:"key"`
+	Expires time.Time       `json:"expires"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// NewDiskCache creates dir if it doesn't exist.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{Dir: dir}, nil
+}
+
+func (cache *DiskCache) filename(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(cache.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (cache *DiskCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(cache.filename(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry diskEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return nil, false
+	}
+	if time.Now().After(entry.Expires) {
+		_ = os.Remove(cache.filename(key))
+		return nil, false
+	}
+	return entry.Data, true
+}
+
+func (cache *DiskCache) Set(key string, data []byte, expires time.Time) {
+	if !json.Valid(data) {
+		return
+	}
+	buffer, err := json.Marshal(diskEntry{Key: key, Expires: expires, Data: data})
+	if err != nil {
+		return
+	}
+	_ = writeFileAtomic(cache.filename(key), buffer)
+}
@@ -0,0 +1,53 @@
+package tt
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	hits := atomic.Int32{}
+	expires := time.Now().Add(time.Hour).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Query().Get("url") == "expiring" {
+			// The signed URL expires within the safety margin, so it isn't cached.
+			expires = time.Now().Add(time.Second * 30).Unix()
+		}
+		_, _ = fmt.Fprintf(w, `{"code":0,"msg":"success","data":{"id":"1","hdplay":"https://v16m.tiktokcdn.com/1.mp4?a=1&x-expires=%d"}}`, expires)
+	}))
+	defer server.Close()
+
+	cache := NewMemoryCache(1)
+	client := &Client{URL: server.URL, Limiter: NoRateLimit, Cache: cache}
+	for range 2 {
+		if _, err := client.GetPost("1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+
+	for range 2 {
+		if _, err := client.GetPost("expiring"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := hits.Load(); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+
+	// The next one evicts the first.
+	expires = time.Now().Add(time.Hour).Unix()
+	if _, err := client.GetPost("2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Get(cacheKey("", map[string]string{"url": "1", "hd": "1"})); ok {
+		t.Errorf("the least recently used response is not evicted")
+	}
+}
@@ -0,0 +1,233 @@
+package tt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotRecorded is returned by a replaying Cassette for requests it has no recording of.
+var ErrNotRecorded = errors.New("request is not recorded")
+
+// CassetteMode tells whether a Cassette records or replays.
+type CassetteMode int
+
+const (
+	// CassetteRecord passes requests to the network and saves them with their responses.
+	CassetteRecord CassetteMode = iota
+	// CassetteReplay serves the saved responses without the network.
+	CassetteReplay
+)
+
+// DefaultRedactedParams are the query parameters and headers a Cassette doesn't save (case-insensitive).
+var DefaultRedactedParams = []string{
+	"token", "access_token", "api_key", "apikey", "key", "secret", "password", "signature", "x-signature", "sig",
+	"authorization", "cookie", "set-cookie", "proxy-authorization",
+}
+
+const redacted = "REDACTED"
+
+// Cassette is an http.RoundTripper that records the API requests and the downloads of a Client to a directory,
+// or replays them from it, see Client.Transport. Every exchange is saved as <N>.json with the request and
+// the response headers, and <N>.body with the response body. Bodies are read to memory before they are passed on.
+//
+// Replay matches requests by method and URL, secrets included as redacted. Repeated requests get the responses
+// in the recorded order, the last one is repeated once they run out. Create it with NewCassette.
+type Cassette struct {
+	// Dir of the recordings.
+	Dir  string
+	Mode CassetteMode
+	// Transport to record from, defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+	// Redact these query parameters and headers, defaults to DefaultRedactedParams.
+	Redact []string
+
+	mu       sync.Mutex
+	next     int
+	recorded map[string][]cassetteEntry
+	served   map[string]int
+}
+
+// cassetteEntry is the content of <N>.json.
+type cassetteEntry struct {
+	Request struct {
+		Method string      `json:"method"`
+		URL    string      `json:"url"`
+		Header http.Header `json:"header"`
+	} `json:"request"`
+	Response struct {
+		StatusCode int         `json:"status_code"`
+		Header     http.Header `json:"header"`
+	} `json:"response"`
+	RecordedAt time.Time `json:"recorded_at"`
+
+	bodyFile string
+}
+
+// NewCassette creates dir for recording, or reads the recordings from it for replaying.
+func NewCassette(dir string, mode CassetteMode) (*Cassette, error) {
+	cassette := &Cassette{Dir: dir, Mode: mode, recorded: map[string][]cassetteEntry{}, served: map[string]int{}}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	// Recordings are replayed in their order, numbers are sorted as numbers.
+	numbers := []int{}
+	for _, filename := range filenames {
+		if n, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(filename), ".json")); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	slices.Sort(numbers)
+	if len(numbers) != 0 {
+		cassette.next = numbers[len(numbers)-1] + 1
+	}
+	if mode == CassetteRecord {
+		return cassette, nil
+	}
+
+	for _, n := range numbers {
+		data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%d.json", n)))
+		if err != nil {
+			return nil, fmt.Errorf("cassette: %w", err)
+		}
+		entry := cassetteEntry{bodyFile: filepath.Join(dir, fmt.Sprintf("%d.body", n))}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("cassette %d.json: %w", n, err)
+		}
+		key := entry.Request.Method + " " + entry.Request.URL
+		cassette.recorded[key] = append(cassette.recorded[key], entry)
+	}
+	return cassette, nil
+}
+
+func (cassette *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	if cassette.Mode == CassetteReplay {
+		return cassette.replay(req)
+	}
+	return cassette.record(req)
+}
+
+func (cassette *Cassette) record(req *http.Request) (*http.Response, error) {
+	transport := cassette.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry := cassetteEntry{RecordedAt: time.Now()}
+	entry.Request.Method = req.Method
+	entry.Request.URL = cassette.redactURL(req.URL)
+	entry.Request.Header = cassette.redactHeader(req.Header)
+	entry.Response.StatusCode = resp.StatusCode
+	entry.Response.Header = cassette.redactHeader(resp.Header)
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	cassette.mu.Lock()
+	n := cassette.next
+	cassette.next++
+	cassette.mu.Unlock()
+	if err := os.WriteFile(filepath.Join(cassette.Dir, fmt.Sprintf("%d.body", n)), body, 0644); err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(cassette.Dir, fmt.Sprintf("%d.json", n)), data, 0644); err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	return resp, nil
+}
+
+func (cassette *Cassette) replay(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + cassette.redactURL(req.URL)
+
+	cassette.mu.Lock()
+	entries := cassette.recorded[key]
+	i := min(cassette.served[key], len(entries)-1)
+	cassette.served[key]++
+	cassette.mu.Unlock()
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("cassette: %s: %w", key, ErrNotRecorded)
+	}
+
+	entry := entries[i]
+	body, err := os.ReadFile(entry.bodyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	contentLength := int64(len(body))
+	if req.Method == http.MethodHead {
+		contentLength = -1
+		if length, err := strconv.ParseInt(entry.Response.Header.Get("Content-Length"), 10, 64); err == nil {
+			contentLength = length
+		}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Response.StatusCode, http.StatusText(entry.Response.StatusCode)),
+		StatusCode:    entry.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.Response.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: contentLength,
+		Request:       req,
+	}, nil
+}
+
+func (cassette *Cassette) redacts(name string) bool {
+	redact := cassette.Redact
+	if redact == nil {
+		redact = DefaultRedactedParams
+	}
+	return slices.ContainsFunc(redact, func(param string) bool { return strings.EqualFold(param, name) })
+}
+
+// redactURL with its query parameters sorted, so it could be matched.
+func (cassette *Cassette) redactURL(u *url.URL) string {
+	redactedURL := *u
+	query := u.Query()
+	for name := range query {
+		if cassette.redacts(name) {
+			query[name] = []string{redacted}
+		}
+	}
+	redactedURL.RawQuery = query.Encode()
+	redactedURL.User = nil
+	return redactedURL.String()
+}
+
+func (cassette *Cassette) redactHeader(header http.Header) http.Header {
+	result := header.Clone()
+	for name := range result {
+		if cassette.redacts(name) {
+			result[name] = []string{redacted}
+		}
+	}
+	return result
+}
@@ -0,0 +1,55 @@
+package tt
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassette(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":0,"msg":"success","data":"` + r.URL.Query().Get("n") + `"}`))
+	}))
+	dir := t.TempDir()
+
+	recorder, err := NewCassette(dir, CassetteRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{URL: server.URL, Limiter: NoRateLimit, Transport: recorder}
+	for _, n := range []string{"1", "2"} {
+		if _, err := client.RawContext(context.Background(), "user/info", map[string]string{"n": n, "token": "hunter2"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.Close()
+
+	recorded, err := os.ReadFile(filepath.Join(dir, "0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(recorded), "hunter2") {
+		t.Errorf("the token is not redacted:\n%s", recorded)
+	}
+
+	player, err := NewCassette(dir, CassetteReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Transport = player
+	var data string
+	if err := client.RawParsedContext(context.Background(), "user/info", map[string]string{"n": "2", "token": "other"}, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data != "2" {
+		t.Errorf("got %q, want %q", data, "2")
+	}
+	if _, err := client.RawContext(context.Background(), "user/info", map[string]string{"n": "3"}); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("got %v, want ErrNotRecorded", err)
+	}
+}
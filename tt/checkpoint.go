@@ -0,0 +1,271 @@
+package tt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Checkpoint of a user feed scan, so a restarted scan picks up where it stopped.
+type Checkpoint struct {
+	// Cursor of the next page to fetch, it's "" once the feed was walked through.
+	Cursor string `json:"cursor"`
+	// Pending posts were fetched before Cursor, but not confirmed with CheckpointStore.Done yet.
+	Pending []Post `json:"pending,omitempty"`
+	// Emitted post ids were confirmed with CheckpointStore.Done, the later scans skip them.
+	Emitted   []string  `json:"emitted"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CheckpointStore keeps checkpoints of user feed scans, see FeedOpt.Checkpoint. It's used concurrently.
+type CheckpointStore interface {
+	// Load returns nil, nil if there's no checkpoint for the user yet.
+	Load(user string) (*Checkpoint, error)
+	// Save the cursor and the pending posts of the scan, Emitted ids are added to the saved ones
+	// and pending posts that are done already are dropped.
+	Save(user string, checkpoint *Checkpoint) error
+	// Done confirms that the post emitted by the scan of the user is handled, i.e. downloaded.
+	// Posts that aren't done are emitted again by the next scan.
+	Done(user string, id string) error
+}
+
+// FileCheckpointStore keeps checkpoints of every user in a single JSON file, it's safe for concurrent use.
+// Done rewrites the file at most once per second, call Flush once the scan is over.
+type FileCheckpointStore struct {
+	path        string
+	mu          sync.Mutex
+	checkpoints map[string]*Checkpoint
+	dirty       bool
+	written     time.Time
+}
+
+var _ CheckpointStore = &FileCheckpointStore{}
+
+// checkpointFlushInterval limits how often FileCheckpointStore.Done rewrites the file.
+const checkpointFlushInterval = time.Second
+
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+func (store *FileCheckpointStore) Load(user string) (*Checkpoint, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.read(); err != nil {
+		return nil, err
+	}
+	checkpoint, ok := store.checkpoints[user]
+	if !ok {
+		return nil, nil
+	}
+	copied := *checkpoint
+	copied.Pending = slices.Clone(checkpoint.Pending)
+	copied.Emitted = slices.Clone(checkpoint.Emitted)
+	return &copied, nil
+}
+
+func (store *FileCheckpointStore) Save(user string, checkpoint *Checkpoint) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.read(); err != nil {
+		return err
+	}
+	saved := store.checkpoint(user)
+	done := map[string]bool{}
+	for _, id := range saved.Emitted {
+		done[id] = true
+	}
+	for _, id := range checkpoint.Emitted {
+		if !done[id] {
+			done[id] = true
+			saved.Emitted = append(saved.Emitted, id)
+		}
+	}
+	saved.Cursor = checkpoint.Cursor
+	saved.Pending = []Post{}
+	for _, post := range checkpoint.Pending {
+		if !done[post.ID()] {
+			saved.Pending = append(saved.Pending, post)
+		}
+	}
+	saved.UpdatedAt = checkpoint.UpdatedAt
+	return store.write()
+}
+
+func (store *FileCheckpointStore) Done(user string, id string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.read(); err != nil {
+		return err
+	}
+	saved := store.checkpoint(user)
+	if !slices.Contains(saved.Emitted, id) {
+		saved.Emitted = append(saved.Emitted, id)
+	}
+	saved.Pending = slices.DeleteFunc(saved.Pending, func(post Post) bool { return post.ID() == id })
+	saved.UpdatedAt = time.Now()
+	store.dirty = true
+	if time.Since(store.written) < checkpointFlushInterval {
+		return nil
+	}
+	return store.write()
+}
+
+// Flush the posts that are done to the file, if Done didn't write them yet.
+func (store *FileCheckpointStore) Flush() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if !store.dirty {
+		return nil
+	}
+	return store.write()
+}
+
+func (store *FileCheckpointStore) checkpoint(user string) *Checkpoint {
+	checkpoint, ok := store.checkpoints[user]
+	if !ok {
+		checkpoint = &Checkpoint{}
+		store.checkpoints[user] = checkpoint
+	}
+	return checkpoint
+}
+
+func (store *FileCheckpointStore) write() error {
+	buffer, err := json.MarshalIndent(store.checkpoints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if err := writeFileAtomic(store.path, buffer); err != nil {
+		return err
+	}
+	store.dirty, store.written = false, time.Now()
+	return nil
+}
+
+func (store *FileCheckpointStore) read() error {
+	if store.checkpoints != nil {
+		return nil
+	}
+	store.checkpoints = map[string]*Checkpoint{}
+
+	buffer, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(buffer, &store.checkpoints); err != nil {
+		store.checkpoints = nil
+		return fmt.Errorf("checkpoint %s: %w", store.path, err)
+	}
+	return nil
+}
+
+// writeFileAtomic never leaves a half-written file under the final name.
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// feedCheckpoint tracks a single scan, it does nothing without a store.
+type feedCheckpoint struct {
+	store      CheckpointStore
+	user       string
+	onError    func(err error)
+	checkpoint Checkpoint
+	// skipped are the posts done by the previous scans and the pending ones, that are emitted before the walk.
+	skipped map[string]bool
+}
+
+// checkpointPages is how often the scans that fetch the whole feed before emitting save the pending posts,
+// they are saved on every page otherwise.
+const checkpointPages = 10
+
+func loadFeedCheckpoint(user string, opt *FeedOpt) (*feedCheckpoint, error) {
+	feed := &feedCheckpoint{store: opt.Checkpoint, user: user, onError: opt.OnError, skipped: map[string]bool{}}
+	if feed.store == nil {
+		return feed, nil
+	}
+
+	checkpoint, err := feed.store.Load(user)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint != nil {
+		feed.checkpoint = *checkpoint
+	}
+	for _, id := range feed.checkpoint.Emitted {
+		feed.skipped[id] = true
+	}
+	for _, post := range feed.checkpoint.Pending {
+		feed.skipped[post.ID()] = true
+	}
+	return feed, nil
+}
+
+// cursor to start the scan from, with the posts fetched before it.
+func (feed *feedCheckpoint) cursor() (string, []Post) {
+	pending := slices.Clone(feed.checkpoint.Pending)
+	if feed.checkpoint.Cursor == "" {
+		return "0", pending
+	}
+	return feed.checkpoint.Cursor, pending
+}
+
+func (feed *feedCheckpoint) isSkipped(post *Post) bool {
+	return feed.skipped[post.ID()]
+}
+
+// fetched all pages before cursor, posts are pending until they are done.
+func (feed *feedCheckpoint) fetched(cursor string, posts []Post) {
+	if feed.store == nil {
+		return
+	}
+	feed.checkpoint.Cursor = cursor
+	feed.checkpoint.Pending = append(feed.checkpoint.Pending, posts...)
+	feed.save()
+}
+
+// walked through the whole feed before emitting it, the posts that aren't done are found by the next scan again.
+func (feed *feedCheckpoint) walked() {
+	if feed.store == nil {
+		return
+	}
+	feed.checkpoint.Cursor = ""
+	feed.checkpoint.Pending = nil
+	feed.save()
+}
+
+func (feed *feedCheckpoint) save() {
+	feed.checkpoint.UpdatedAt = time.Now()
+	if err := feed.store.Save(feed.user, &feed.checkpoint); err != nil {
+		feed.onError(fmt.Errorf("could not save checkpoint: %w", err))
+		return
+	}
+	// The store drops the pending posts that are done meanwhile.
+	if saved, err := feed.store.Load(feed.user); err == nil && saved != nil {
+		feed.checkpoint.Pending = saved.Pending
+	}
+}
@@ -0,0 +1,74 @@
+package tt_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/heilkit/tt/tt"
+	"github.com/heilkit/tt/tt/tttest"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	client.MaxUserFeedCount = 1
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	store := tt.NewFileCheckpointStore(path)
+
+	// The scan is interrupted after 2 posts, only the first one is done.
+	ids := []string{}
+	for post, err := range client.UserPosts(context.Background(), "losertron", tt.FeedOpt{SD: true, Checkpoint: store}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids = append(ids, post.ID()); len(ids) == 1 {
+			if err := store.Done("losertron", post.ID()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(ids) == 2 {
+			break
+		}
+	}
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	hits := server.Hits(tttest.MethodUserFeed)
+
+	// The second one is emitted again, and the walk goes on from the third page.
+	store = tt.NewFileCheckpointStore(path)
+	ids = []string{}
+	for post, err := range client.UserPosts(context.Background(), "losertron", tt.FeedOpt{SD: true, Checkpoint: store}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, post.ID())
+	}
+	want := []string{"7301000000000000003", "7301000000000000002", "7301000000000000001"}
+	if !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+	if pages := server.Hits(tttest.MethodUserFeed) - hits; pages != 2 {
+		t.Errorf("got %d pages on resume, want 2", pages)
+	}
+
+	// A post taken from the channel, but not done, isn't lost.
+	ctx, cancel := context.WithCancel(context.Background())
+	postChan, _, err := client.GetUserFeedContext(ctx, "losertron", tt.FeedOpt{SD: true, Checkpoint: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-postChan
+	cancel()
+	for range postChan {
+	}
+	posts, err := client.GetUserFeedAwait("losertron", tt.FeedOpt{SD: true, Checkpoint: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 3 || posts[0].ID() != "7301000000000000001" {
+		t.Errorf("got %d posts, want 3 starting from the oldest one", len(posts))
+	}
+}
@@ -0,0 +1,169 @@
+package tt
+
+import (
+	"context"
+	"github.com/cavaliergopher/grab/v3"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Client holds everything needed to talk to a tikwm-compatible API, so several clients with different endpoints,
+// rate limits and HTTP clients can live in one process.
+// The zero value is usable: unset fields fall back to the package-level defaults (URL, MaxUserFeedCount, Debug,
+// DefaultDownloadGrabClient, DefaultDownloadMutex), and the rate limit is shared with the package-level functions.
+type Client struct {
+	// URL of the API, defaults to tt.URL.
+	URL string
+	// Mirrors are tikwm-compatible APIs used instead of URL when set. Requests go to the first healthy one,
+	// retries go to the next one, failing ones are skipped for a while, see Health and Stats.
+	Mirrors []string
+	// Health of the endpoints, defaults to a tracker shared with the package-level functions.
+	Health *HealthTracker
+	// Backends answer GetPost, GetUserFeed and GetUserDetail in order, the next one is tried when one fails,
+	// defaults to Tikwm at URL.
+	Backends []Backend
+	// HTTPClient used for API requests, defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Limiter paces API requests, defaults to a limiter shared with the package-level functions
+	// allowing one request per tt.Timeout.
+	Limiter RateLimiter
+	// Retry failed API requests, defaults to DefaultRetryPolicy.
+	Retry *RetryPolicy
+	// MaxUserFeedCount is the page size for user feeds and searches, defaults to tt.MaxUserFeedCount.
+	MaxUserFeedCount int
+	// Debug logs raw API responses, tt.Debug enables it for every client.
+	Debug bool
+	// Log defaults to slog.Default().
+	Log *slog.Logger
+	// Grab downloads files, defaults to DefaultDownloadGrabClient.
+	Grab *grab.Client
+	// Cache successful responses of RawParsed and everything built on it (GetPost, GetUserDetail...), nil disables it.
+	Cache Cache
+	// CacheTTL per method, defaults to DefaultCacheTTL. Responses with signed CDN URLs expire no later than the URLs.
+	CacheTTL map[string]time.Duration
+	// Transport replaces the transport of HTTPClient and Grab when it's set, i.e. a Cassette to record or replay sessions.
+	Transport http.RoundTripper
+	// DownloadMutex serializes Post.Download calls unless DownloadOpt.NoSync is set, defaults to DefaultDownloadMutex.
+	DownloadMutex *sync.Mutex
+}
+
+// DefaultClient is used by the package-level functions.
+var DefaultClient = &Client{}
+
+// NewClient returns a client initialized with the current package-level defaults,
+// but with its own rate limiter, download mutex and grab client.
+func NewClient() *Client {
+	return &Client{
+		URL:              URL,
+		HTTPClient:       http.DefaultClient,
+		Limiter:          timeoutRateLimiter(Timeout),
+		MaxUserFeedCount: MaxUserFeedCount,
+		Debug:            Debug,
+		Log:              slog.Default(),
+		Grab:             defaultGrab(),
+		DownloadMutex:    &sync.Mutex{},
+	}
+}
+
+func (c *Client) url() string {
+	if c.URL != "" {
+		return c.URL
+	}
+	return URL
+}
+
+func (c *Client) httpClient() *http.Client {
+	client := http.DefaultClient
+	if c.HTTPClient != nil {
+		client = c.HTTPClient
+	}
+	if c.Transport != nil {
+		return withTransportOf(client, c.Transport)
+	}
+	return client
+}
+
+func (c *Client) limiter() RateLimiter {
+	if c.Limiter != nil {
+		return c.Limiter
+	}
+	defaultRateLimiterOnce.Do(func() {
+		defaultRateLimiter = timeoutRateLimiter(Timeout)
+	})
+	return defaultRateLimiter
+}
+
+func (c *Client) retryPolicy() *RetryPolicy {
+	policy := RetryPolicy{}
+	if c.Retry != nil {
+		policy = *c.Retry
+	}
+	return policy.WithDefaults()
+}
+
+func (c *Client) maxUserFeedCount() int {
+	if c.MaxUserFeedCount != 0 {
+		return c.MaxUserFeedCount
+	}
+	return MaxUserFeedCount
+}
+
+func (c *Client) debug() bool {
+	return c.Debug || Debug
+}
+
+func (c *Client) log() *slog.Logger {
+	if c.Log != nil {
+		return c.Log
+	}
+	return slog.Default()
+}
+
+func (c *Client) grab() *grab.Client {
+	client := DefaultDownloadGrabClient
+	if c.Grab != nil {
+		client = c.Grab
+	}
+	if c.Transport == nil {
+		return client
+	}
+
+	withTransport := *client
+	withTransport.HTTPClient = withTransportOf(client.HTTPClient, c.Transport)
+	return &withTransport
+}
+
+// withTransportOf returns a copy of the client using transport, keeping its timeout.
+func withTransportOf(client grab.HTTPClient, transport http.RoundTripper) *http.Client {
+	result := &http.Client{Transport: transport}
+	if httpClient, ok := client.(*http.Client); ok {
+		result.Timeout = httpClient.Timeout
+		result.Jar = httpClient.Jar
+		result.CheckRedirect = httpClient.CheckRedirect
+	}
+	return result
+}
+
+func (c *Client) downloadMutex() *sync.Mutex {
+	if c.DownloadMutex != nil {
+		return c.DownloadMutex
+	}
+	return DefaultDownloadMutex
+}
+
+type clientKey struct{}
+
+// withClient passes the client of a download to its post processors, see clientOf.
+func withClient(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// clientOf the download ctx belongs to, DefaultClient outside of downloads.
+func clientOf(ctx context.Context) *Client {
+	if c, ok := ctx.Value(clientKey{}).(*Client); ok {
+		return c
+	}
+	return DefaultClient
+}
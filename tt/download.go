@@ -1,12 +1,15 @@
 package tt
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/cavaliergopher/grab/v3"
 	"io"
 	"log/slog"
 	"net/http"
+	"os"
 	"os/exec"
 	"path"
 	"sync"
@@ -26,13 +29,17 @@ type DownloadOpt struct {
 	Filename string
 	// Directory download to.
 	Directory string
-	// DownloadWith specifies how singe files are downloaded.
+	// DownloadWith specifies how singe files are downloaded. The filename is <file>.part, it should be resumed if it exists.
 	DownloadWith func(url string, filename string) error
-	// ValidateWith function your downloads, by default do nothing.
+	// DownloadWithContext is DownloadWith, that is able to stop once ctx is done. It's preferred over DownloadWith.
+	DownloadWithContext func(ctx context.Context, url string, filename string) error
+	// ValidateWith function your downloads, by default do nothing. It gets the <file>.part, invalid ones are removed
+	// and retried with ErrCorrupt. tt.ValidateWithFfprobe from the package.
 	ValidateWith func(filename string) (bool, error)
 	// Fallback in case something goes wrong, by default there's no Fallback. tt.FallbackToSD from the package.
 	Fallback func(post *Post, opt DownloadOpt, err error) (files []string, e error)
 	// FilenameFormat defaults to i.e. "canthinky_2022-12-21_7179438804418268417.mp4", unless other function is provided.
+	// Directories in it are created, see FilenameTemplate for a simpler way to format.
 	FilenameFormat func(post *Post, i int) string
 	// Timeout set to 0 is actually gets set to time.Second, if you want real no timeout use Timeout=time.Nanosecond.
 	Timeout time.Duration
@@ -40,12 +47,38 @@ type DownloadOpt struct {
 	TimeoutOnError time.Duration
 	// NoSync allows parallel downloads, by default it's disallowed.
 	NoSync bool
+	// Scheduler downloads files of the post in parallel within its limits, ignoring NoSync and Timeout.
+	Scheduler *Scheduler
 	// Retries set to 0 is interpreted as no value, so it's getting set to DownloadDefaultReties. To have to Retries set it to -1.
+	// Only transient errors are retried, see IsTransient.
 	Retries int
 	// Download post in SD quality.
 	SD bool
+	// WriteInfoJSON writes <file>.info.json next to the downloaded files, see InfoJSON.
+	WriteInfoJSON bool
+	// WriteDescription writes the title of the post to <file>.description.
+	WriteDescription bool
+	// WriteCaption writes a human-readable summary of the post to <file>.txt.
+	WriteCaption bool
+	// Quality chooses the source of videos, by default HD (unless SD), SD, and watermarked are tried in order.
+	Quality *QualityPolicy
+	// OnDownloaded is called for every downloaded file before PostProcessors, i.e. to tell the Variant of videos.
+	OnDownloaded func(file DownloadedFile)
+	// Include assets of the post besides its content, nil means DefaultAssets, see ParseAssets.
+	Include []Asset
+	// EmbedMetadata into the downloaded videos, by default do nothing. tt.EmbedMetadataMP4 or tt.EmbedMetadataWithFfmpeg from the package.
+	// It runs before PostProcessors.
+	EmbedMetadata func(ctx context.Context, post *Post, filename string) error
+	// PostProcessors run one after another over the downloaded files, their final filenames are returned.
+	// Sidecars are written next to the first of them. tt.HashSHA256, tt.MoveTo and tt.Exec from the package.
+	PostProcessors []PostProcessor
+	// Archive skips posts that are listed in it with ErrArchived, and lists the downloaded ones.
+	Archive *Archive
 	// Log if you need it.
 	Log *slog.Logger
+
+	client *Client
+	ctx    context.Context
 }
 
 func (opt *DownloadOpt) WithDefaults() *DownloadOpt {
@@ -77,7 +110,19 @@ func (opt *DownloadOpt) WithDefaults() *DownloadOpt {
 	if opt.Log == nil {
 		opt.Log = slog.New(slog.NewTextHandler(io.Discard, nil))
 	}
+	if opt.Quality == nil {
+		opt.Quality = defaultQuality(opt.SD)
+	}
 
+	if opt.DownloadWithContext == nil {
+		if downloadWith := opt.DownloadWith; downloadWith != nil {
+			opt.DownloadWithContext = func(_ context.Context, url string, filename string) error {
+				return downloadWith(url, filename)
+			}
+		} else {
+			opt.DownloadWithContext = DownloadFileWithContext
+		}
+	}
 	if opt.DownloadWith == nil {
 		opt.DownloadWith = DownloadFileWith
 	}
@@ -89,74 +134,279 @@ func (opt *DownloadOpt) WithDefaults() *DownloadOpt {
 }
 
 func Download(url string, opt ...*DownloadOpt) (post *Post, filenames []string, err error) {
+	return DefaultClient.DownloadContext(context.Background(), url, opt...)
+}
+
+func DownloadContext(ctx context.Context, url string, opt ...*DownloadOpt) (post *Post, filenames []string, err error) {
+	return DefaultClient.DownloadContext(ctx, url, opt...)
+}
+
+func (c *Client) Download(url string, opt ...*DownloadOpt) (post *Post, filenames []string, err error) {
+	return c.DownloadContext(context.Background(), url, opt...)
+}
+
+func (c *Client) DownloadContext(ctx context.Context, url string, opt ...*DownloadOpt) (post *Post, filenames []string, err error) {
 	opts := &DownloadOpt{}
 	if len(opt) > 0 {
 		opts = opt[0]
 	}
-	post, err = GetPost(url, !opts.SD)
+	post, err = c.GetPostContext(ctx, url, !opts.SD)
 	if err != nil {
 		return nil, nil, fmt.Errorf("Download -> GetPost: %w", err)
 	}
-	files, err := post.Download(opts)
+	files, err := c.DownloadPostContext(ctx, post, opts)
 	return post, files, err
 }
 
 func DownloadSingle(filename string, opt ...*DownloadOpt) (filenames string, err error) {
+	return DefaultClient.DownloadSingleContext(context.Background(), filename, opt...)
+}
+
+func DownloadSingleContext(ctx context.Context, filename string, opt ...*DownloadOpt) (filenames string, err error) {
+	return DefaultClient.DownloadSingleContext(ctx, filename, opt...)
+}
+
+func (c *Client) DownloadSingle(filename string, opt ...*DownloadOpt) (filenames string, err error) {
+	return c.DownloadSingleContext(context.Background(), filename, opt...)
+}
+
+func (c *Client) DownloadSingleContext(ctx context.Context, filename string, opt ...*DownloadOpt) (filenames string, err error) {
 	opts := &DownloadOpt{}
 	if len(opt) > 0 {
 		opts = opt[0]
 	}
-	post, err := GetPost(filename, !opts.SD)
+	post, err := c.GetPostContext(ctx, filename, !opts.SD)
 	if err != nil {
 		return "", fmt.Errorf("DownloadSingle -> GetPost: %w", err)
 	}
-	files, err := post.Download(opts)
+	files, err := c.DownloadPostContext(ctx, post, opts)
+	if len(files) == 0 {
+		return "", err
+	}
 	return files[0], err
 }
 
 func (post Post) Download(opt ...*DownloadOpt) (filenames []string, err error) {
-	opts := &DownloadOpt{}
-	if len(opt) != 0 {
-		opts = opt[0]
+	return DefaultClient.DownloadPostContext(context.Background(), &post, opt...)
+}
+
+func (post Post) DownloadContext(ctx context.Context, opt ...*DownloadOpt) (filenames []string, err error) {
+	return DefaultClient.DownloadPostContext(ctx, &post, opt...)
+}
+
+// DownloadPost is Post.Download using the client's grab client and download mutex.
+func (c *Client) DownloadPost(post *Post, opt ...*DownloadOpt) (filenames []string, err error) {
+	return c.DownloadPostContext(context.Background(), post, opt...)
+}
+
+// DownloadPostContext stops the transfers, retries and timeouts as soon as ctx is done, returning ctx.Err().
+func (c *Client) DownloadPostContext(ctx context.Context, post *Post, opt ...*DownloadOpt) (filenames []string, err error) {
+	opts := DownloadOpt{}
+	if len(opt) != 0 && opt[0] != nil {
+		opts = *opt[0]
+	}
+	ctx = withClient(ctx, c)
+	opts.client = c
+	opts.ctx = ctx
+	if opts.DownloadWith == nil && opts.DownloadWithContext == nil {
+		opts.DownloadWith = c.DownloadFileWith
+		opts.DownloadWithContext = c.DownloadFileWithContext
+	}
+	if opts.Archive != nil && opts.Archive.Has(post.ID()) {
+		return nil, ErrArchived
+	}
+
+	filenames, err = opts.WithDefaults().download(ctx, post)
+	if err == nil && opts.Archive != nil {
+		err = opts.Archive.Add(post.ID())
+	}
+	return filenames, err
+}
+
+func (opts *DownloadOpt) download(ctx context.Context, post *Post) (filenames []string, err error) {
+	files, err := opts.assets(post)
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	urls := []string{}
+	for _, file := range files {
+		if err := os.MkdirAll(path.Dir(file.Filename), 0755); err != nil {
+			return nil, fmt.Errorf("download: %w", err)
+		}
+		urls = append(urls, file.URL)
+		filenames = append(filenames, file.Filename)
+	}
+
+	if opts.Scheduler != nil {
+		err = opts.downloadScheduled(ctx, post, urls, filenames)
+	} else {
+		err = opts.downloadSync(ctx, post, urls, filenames)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return opts.Fallback(post, *opts, fmt.Errorf("download: %w", err))
+	}
+	if opts.OnDownloaded != nil {
+		for _, file := range files {
+			opts.OnDownloaded(file)
+		}
+	}
+	if filenames, err = opts.postProcess(ctx, post, filenames); err != nil {
+		return filenames, fmt.Errorf("download: %w", err)
+	}
+	if err := opts.writeSidecars(post, urls, filenames); err != nil {
+		return filenames, fmt.Errorf("download: %w", err)
 	}
-	opts = opts.WithDefaults()
+
+	return filenames, nil
+}
+
+// downloadSync downloads files one by one, waiting for Timeout before each.
+func (opts *DownloadOpt) downloadSync(ctx context.Context, post *Post, urls []string, filenames []string) error {
 	if !opts.NoSync {
-		DefaultDownloadMutex.Lock()
-		defer DefaultDownloadMutex.Unlock()
-	}
-
-	for i, url := range post.ContentUrls(!opts.SD) {
-		time.Sleep(opts.Timeout)
-		filename := path.Join(opts.Directory, opts.FilenameFormat(&post, i))
-		if err := opts.DownloadWith(url, filename); err != nil {
-			for try := 0; try < opts.Retries || err == nil; try++ {
-				opts.Log.Warn("Download failed, retrying...", "err", err, "try", try+1)
-				time.Sleep(opts.TimeoutOnError)
-				err = opts.DownloadWith(url, filename)
-			}
-			//goland:noinspection GoDfaConstantCondition -- this is correct, bc `for` loop before ends if err == nil.
+		opts.client.downloadMutex().Lock()
+		defer opts.client.downloadMutex().Unlock()
+	}
+
+	for i, url := range urls {
+		if err := sleepContext(ctx, opts.Timeout); err != nil {
+			return err
+		}
+		if err := opts.downloadFile(ctx, url, filenames[i], post.expectedSize(url)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadScheduled downloads files in parallel within the limits of Scheduler, the first failure stops the rest.
+func (opts *DownloadOpt) downloadScheduled(ctx context.Context, post *Post, urls []string, filenames []string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var firstErr error
+	once := sync.Once{}
+	wg := sync.WaitGroup{}
+	for i, url := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := opts.Scheduler.run(ctx, url, filenames[i], func(ctx context.Context) error {
+				return opts.downloadFile(ctx, url, filenames[i], post.expectedSize(url))
+			})
 			if err != nil {
-				return opts.Fallback(&post, *opts, fmt.Errorf("download: %w", err))
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
 			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// partSuffix of the files being downloaded, they are renamed into place once complete.
+const partSuffix = ".part"
+
+// downloadFile retries transient failures of DownloadWithContext. The file is downloaded to <file>.part, which is resumed
+// by the retries, and it's renamed into place once it has the expected size and passes ValidateWith. Sizes <= 0 aren't checked.
+func (opts *DownloadOpt) downloadFile(ctx context.Context, url string, filename string, size int64) error {
+	if stat, err := os.Stat(filename); err == nil && size > 0 && stat.Size() == size {
+		opts.Log.Debug("File is downloaded already", "file", filename)
+		return nil
+	}
+
+	part := filename + partSuffix
+	err := opts.downloadPart(ctx, url, part, size)
+	// IsTransient is false for nil and context errors, permanent failures like 404 aren't retried.
+	for try := 0; try < opts.Retries && IsTransient(err); try++ {
+		opts.Log.Warn("Download failed, retrying...", "err", err, "try", try+1, "file", filename)
+		if err := sleepContext(ctx, opts.TimeoutOnError); err != nil {
+			return err
 		}
-		filenames = append(filenames, filename)
+		err = opts.downloadPart(ctx, url, part, size)
+	}
+	if err != nil {
+		return err
 	}
+	return os.Rename(part, filename)
+}
 
-	return filenames, err
+// downloadPart downloads or resumes part, a corrupt one is removed to start over.
+func (opts *DownloadOpt) downloadPart(ctx context.Context, url string, part string, size int64) error {
+	if err := opts.DownloadWithContext(ctx, url, part); err != nil {
+		if errors.Is(err, grab.ErrBadLength) {
+			_ = os.Remove(part)
+		}
+		return err
+	}
+
+	stat, err := os.Stat(part)
+	if err != nil {
+		return err
+	}
+	if size > 0 && stat.Size() != size {
+		_ = os.Remove(part)
+		return fmt.Errorf("%w: %s has %d bytes, expected %d", ErrCorrupt, part, stat.Size(), size)
+	}
+	if valid, err := opts.ValidateWith(part); err != nil || !valid {
+		_ = os.Remove(part)
+		return fmt.Errorf("%w: %s is not valid: %v", ErrCorrupt, part, err)
+	}
+	return nil
 }
 
 func DownloadFileWith(url string, filename string) error {
+	return DefaultClient.DownloadFileWithContext(context.Background(), url, filename)
+}
+
+func DownloadFileWithContext(ctx context.Context, url string, filename string) error {
+	return DefaultClient.DownloadFileWithContext(ctx, url, filename)
+}
+
+// DownloadFileWith downloads a single file with the client's grab client.
+func (c *Client) DownloadFileWith(url string, filename string) error {
+	return c.DownloadFileWithContext(context.Background(), url, filename)
+}
+
+func (c *Client) DownloadFileWithContext(ctx context.Context, url string, filename string) error {
 	req, err := grab.NewRequest(filename, url)
 	if err != nil {
 		return fmt.Errorf("grab.NewRequest: %w", err)
 	}
 
-	if resp := DefaultDownloadGrabClient.Do(req); resp.Err() != nil {
+	resp := c.grab().Do(req.WithContext(ctx))
+	if transfer := transferFromContext(ctx); transfer != nil {
+		reportProgress(resp, transfer)
+	}
+	if resp.Err() != nil {
 		return fmt.Errorf("grab.Do: %w", resp.Err())
 	}
 	return nil
 }
 
+// reportProgress of resp to the transfer until it's done.
+func reportProgress(resp *grab.Response, transfer *Transfer) {
+	ticker := time.NewTicker(time.Millisecond * 200)
+	defer ticker.Stop()
+	for {
+		if size := resp.Size(); size > 0 {
+			transfer.size.Store(size)
+		}
+		transfer.complete.Store(resp.BytesComplete())
+		select {
+		case <-resp.Done:
+			transfer.complete.Store(resp.BytesComplete())
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func DownloadTo(filename string) func(post *Post, i int) string {
 	return func(post *Post, i int) string {
 		return filename
@@ -166,8 +416,17 @@ func DownloadTo(filename string) func(post *Post, i int) string {
 func FallbackToSD(post *Post, opt DownloadOpt, err error) (filenames []string, e error) {
 	opt.Log.Warn("Downloading failed, falling back to SD", "post", post.ID(), "err", err)
 	opt.SD = true
+	if opt.Quality != nil {
+		opt.Quality = opt.Quality.withoutHD()
+	}
 	opt.Fallback = fallbackNone
-	return post.Download(&opt)
+	if opt.client == nil {
+		opt.client = DefaultClient
+	}
+	if opt.ctx == nil {
+		opt.ctx = context.Background()
+	}
+	return opt.client.DownloadPostContext(opt.ctx, post, &opt)
 }
 
 func fallbackNone(post *Post, opt DownloadOpt, err error) (files []string, e error) {
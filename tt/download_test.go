@@ -1,8 +1,14 @@
 package tt
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSingleDownload(t *testing.T) {
@@ -16,3 +22,42 @@ func TestSingleDownload(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestPartialDownload(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "video.mp4", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	post := &Post{VideoId: "1", Play: server.URL, Size: int64(len(content))}
+	opt := &DownloadOpt{Directory: dir, Filename: "video.mp4", Timeout: time.Nanosecond, Retries: -1}
+	filename := filepath.Join(dir, "video.mp4")
+
+	// A half of the file is left by a previous run.
+	if err := os.WriteFile(filename+partSuffix, []byte(content[:len(content)/2]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient().DownloadPost(post, opt); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filename); err != nil || string(data) != content {
+		t.Fatalf("content mismatch: %v", err)
+	}
+	if _, err := os.Stat(filename + partSuffix); !os.IsNotExist(err) {
+		t.Errorf("%s is left behind", filename+partSuffix)
+	}
+
+	// A truncated file never gets the final name.
+	post.VideoId, post.Size = "2", int64(len(content)+1)
+	opt.Filename = "truncated.mp4"
+	if _, err := NewClient().DownloadPost(post, opt); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("got %v, want ErrCorrupt", err)
+	}
+	for _, name := range []string{"truncated.mp4", "truncated.mp4" + partSuffix} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s is left behind", name)
+		}
+	}
+}
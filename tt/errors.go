@@ -0,0 +1,133 @@
+package tt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cavaliergopher/grab/v3"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors to match *APIError and *HTTPError with errors.Is.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrPrivate     = errors.New("private account")
+	ErrRateLimited = errors.New("rate limited")
+	ErrBadURL      = errors.New("bad url")
+)
+
+// ErrUnexpectedResponse is wrapped in *HTTPError when the data of the response doesn't match its Go type,
+// i.e. once tikwm changes the shape of its responses. Retrying it doesn't help.
+var ErrUnexpectedResponse = errors.New("unexpected response")
+
+// ErrNoData is returned when the API answers with code 0, but without data.
+var ErrNoData = errors.New("no data")
+
+// ErrCorrupt is returned for downloaded files of unexpected size or ones that DownloadOpt.ValidateWith rejects.
+var ErrCorrupt = errors.New("downloaded file is corrupt")
+
+// APIError is returned when tikwm answers with a non-zero code.
+type APIError struct {
+	Code          int
+	Msg           string
+	Method        string
+	Query         map[string]string
+	ProcessedTime float64
+}
+
+func (e *APIError) Error() string {
+	queryStr := "???"
+	if buf, err := json.Marshal(e.Query); err == nil {
+		queryStr = string(buf)
+	}
+	return fmt.Sprintf("tikwm error: %s (%d) [%s, query: %s]", e.Msg, e.Code, e.Method, queryStr)
+}
+
+// Is matches the sentinel errors. tikwm answers with code -1 for almost everything, so it relies on the message.
+func (e *APIError) Is(target error) bool {
+	msg := strings.ToLower(e.Msg)
+	switch target {
+	case ErrRateLimited:
+		return strings.Contains(msg, "limit")
+	case ErrPrivate:
+		return strings.Contains(msg, "private")
+	case ErrBadURL:
+		return strings.Contains(msg, "url parsing") || strings.Contains(msg, "check url") || strings.Contains(msg, "invalid url")
+	case ErrNotFound:
+		return strings.Contains(msg, "not exist") || strings.Contains(msg, "not found") ||
+			strings.Contains(msg, "unavailable") || strings.Contains(msg, "deleted")
+	}
+	return false
+}
+
+// HTTPError is returned when the API answers with a non-2xx status or a body that isn't JSON.
+type HTTPError struct {
+	StatusCode int
+	Method     string
+	// Body is the beginning of the response body.
+	Body string
+	// Err is the parsing error for 2xx responses.
+	Err error
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("tikwm http error: %d %s [%s]: %s: %q", e.StatusCode, http.StatusText(e.StatusCode), e.Method, e.Err, e.Body)
+	}
+	return fmt.Sprintf("tikwm http error: %d %s [%s]: %q", e.StatusCode, http.StatusText(e.StatusCode), e.Method, e.Body)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// IsTransient reports whether retrying err could help: network failures, 5xx and 429 statuses, rate limits,
+// broken responses and corrupt downloads are transient, other API errors, responses of unexpected shape, cancelled
+// contexts and requests a Cassette has no recording of are permanent.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrNotRecorded) ||
+		errors.Is(err, ErrUnexpectedResponse) {
+		return false
+	}
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Err != nil || transientStatus(httpErr.StatusCode)
+	}
+	var statusErr grab.StatusCodeError
+	if errors.As(err, &statusErr) {
+		return transientStatus(int(statusErr))
+	}
+	return true
+}
+
+func transientStatus(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+}
+
+func bodySnippet(body []byte) string {
+	const maxLen = 256
+	if len(body) > maxLen {
+		return string(body[:maxLen]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,40 @@
+package tt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cavaliergopher/grab/v3"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	rateLimited := &APIError{Code: -1, Msg: "Free Api Limit: 1 request/second."}
+	badURL := &APIError{Code: -1, Msg: "Url parsing is failed! Please check url."}
+	brokenJSON := &HTTPError{StatusCode: 200, Err: errors.New("invalid character '<'")}
+	wrongShape := &HTTPError{StatusCode: 200, Err: fmt.Errorf("%w: wrong type", ErrUnexpectedResponse)}
+
+	if !errors.Is(fmt.Errorf("wrapped: %w", rateLimited), ErrRateLimited) || errors.Is(rateLimited, ErrNotFound) {
+		t.Error("rate limit is not matched")
+	}
+	if !errors.Is(badURL, ErrBadURL) {
+		t.Error("bad url is not matched")
+	}
+
+	for err, transient := range map[error]bool{
+		rateLimited:                    true,
+		badURL:                         false,
+		context.Canceled:               false,
+		&HTTPError{StatusCode: 502}:    true,
+		&HTTPError{StatusCode: 404}:    false,
+		brokenJSON:                     true,
+		wrongShape:                     false,
+		grab.StatusCodeError(503):      true,
+		grab.StatusCodeError(403):      false,
+		errors.New("connection reset"): true,
+	} {
+		if IsTransient(err) != transient {
+			t.Errorf("IsTransient(%v) != %v", err, transient)
+		}
+	}
+}
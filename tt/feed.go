@@ -1,7 +1,10 @@
 package tt
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 )
 
@@ -30,12 +33,22 @@ type FeedOpt struct {
 	Filter Predicate
 	// While to continue scanning (default: scan all)
 	While Predicate
-	// OnError could panic to interrupt the job (default: log the error)
+	// OnError could panic to interrupt the job, cancelling the context of GetUserFeedContext is cleaner (default: log the error)
 	OnError func(err error)
 	// ReturnChan == nil, then it will be created inside the function.
 	// ReturnChan is closed when scanning subroutine is done.
 	ReturnChan chan Post
 	SD         bool
+	// Checkpoint makes scans resumable: posts confirmed with CheckpointStore.Done by the previous scans of the user
+	// are skipped, emitted posts that aren't confirmed are emitted again, and an interrupted walk through the pages
+	// continues from the last cursor (default: no checkpoints).
+	Checkpoint CheckpointStore
+	// HDWorkers resolve HD versions of posts in parallel, still within the rate limit of the client (default: 1).
+	HDWorkers int
+	// KeepOrder of posts with HDWorkers > 1, otherwise posts are emitted as soon as they are resolved.
+	KeepOrder bool
+	// OldestFirst makes GetUserFeedStream buffer the whole feed and emit it oldest-first, like GetUserFeed does.
+	OldestFirst bool
 }
 
 func (opt *FeedOpt) Defaults() *FeedOpt {
@@ -60,7 +73,19 @@ func (opt *FeedOpt) Defaults() *FeedOpt {
 }
 
 func GetUserFeedAwait(uniqueID string, opts ...FeedOpt) ([]Post, error) {
-	postChan, _, err := GetUserFeed(uniqueID, opts...)
+	return DefaultClient.GetUserFeedAwaitContext(context.Background(), uniqueID, opts...)
+}
+
+func GetUserFeedAwaitContext(ctx context.Context, uniqueID string, opts ...FeedOpt) ([]Post, error) {
+	return DefaultClient.GetUserFeedAwaitContext(ctx, uniqueID, opts...)
+}
+
+func (c *Client) GetUserFeedAwait(uniqueID string, opts ...FeedOpt) ([]Post, error) {
+	return c.GetUserFeedAwaitContext(context.Background(), uniqueID, opts...)
+}
+
+func (c *Client) GetUserFeedAwaitContext(ctx context.Context, uniqueID string, opts ...FeedOpt) ([]Post, error) {
+	postChan, _, err := c.GetUserFeedContext(ctx, uniqueID, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -68,25 +93,49 @@ func GetUserFeedAwait(uniqueID string, opts ...FeedOpt) ([]Post, error) {
 	for post := range postChan {
 		ret = append(ret, post)
 	}
-	return ret, nil
+	return ret, ctx.Err()
 }
 
 // GetUserFeed to a channel, getting HD versions of files could take a while.
 // If you are okay with waiting for minutes ((1-2 secs) * len_of_videos), consider GetUserFeedAwait
 func GetUserFeed(uniqueID string, opts ...FeedOpt) (chan Post, int, error) {
+	return DefaultClient.GetUserFeedContext(context.Background(), uniqueID, opts...)
+}
+
+// GetUserFeedContext is GetUserFeed, that stops as soon as ctx is done. The channel is closed in that case,
+// check ctx.Err() to tell an interrupted feed from a complete one.
+func GetUserFeedContext(ctx context.Context, uniqueID string, opts ...FeedOpt) (chan Post, int, error) {
+	return DefaultClient.GetUserFeedContext(ctx, uniqueID, opts...)
+}
+
+// GetUserFeed to a channel, getting HD versions of files could take a while, consider GetUserFeedAwait.
+func (c *Client) GetUserFeed(uniqueID string, opts ...FeedOpt) (chan Post, int, error) {
+	return c.GetUserFeedContext(context.Background(), uniqueID, opts...)
+}
+
+func (c *Client) GetUserFeedContext(ctx context.Context, uniqueID string, opts ...FeedOpt) (chan Post, int, error) {
 	var opt *FeedOpt = nil
 	if len(opts) != 0 {
 		opt = &opts[0]
 	}
 	opt = opt.Defaults()
 
-	posts, err := userFeedUntilInternal(uniqueID, "0", opt)
+	checkpoint, err := loadFeedCheckpoint(uniqueID, opt)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	posts, err := c.userFeedUntilInternal(ctx, uniqueID, opt, checkpoint)
+	if ctx.Err() != nil {
+		return nil, 0, ctx.Err()
+	}
 	if err != nil {
 		return nil, 0, err
 	}
 	for i := 0; i < len(posts)/2; i++ {
 		posts[i], posts[len(posts)-i-1] = posts[len(posts)-i-1], posts[i]
 	}
+	checkpoint.walked()
 
 	go func() {
 		defer func() {
@@ -97,52 +146,182 @@ func GetUserFeed(uniqueID string, opts ...FeedOpt) (chan Post, int, error) {
 		}()
 
 		defer close(opt.ReturnChan)
-		for _, post := range posts {
-			if opt.SD {
-				opt.ReturnChan <- post
-				continue
-			}
-
-			vidHD, err := GetPost(post.VideoId, true)
-			if err != nil {
-				opt.OnError(err)
-				opt.ReturnChan <- post
-			} else {
-				opt.ReturnChan <- *vidHD
-			}
-		}
+		c.resolveHDAll(ctx, posts, opt, func(post Post, err error) bool {
+			return c.sendPost(ctx, post, err, opt)
+		})
 	}()
 
 	return opt.ReturnChan, len(posts), err
 }
 
-func userFeedUntilInternal(uniqueID string, cursor string, opt *FeedOpt) ([]Post, error) {
-	feed, err := GetUserFeedRaw(uniqueID, MaxUserFeedCount, cursor)
+// sendPost sends the post to opt.ReturnChan, err of the HD lookup goes to opt.OnError. It's false once ctx is done.
+func (c *Client) sendPost(ctx context.Context, post Post, err error, opt *FeedOpt) bool {
+	if ctx.Err() != nil {
+		return false
+	}
 	if err != nil {
-		return nil, err
+		opt.OnError(err)
 	}
 
-	ret := []Post{}
-	if len(feed.Videos) > MaxUserFeedCount {
-		feed.Videos = feed.Videos[:MaxUserFeedCount]
+	select {
+	case opt.ReturnChan <- post:
+	case <-ctx.Done():
+		return false
 	}
-	for _, vid := range feed.Videos {
-		if !opt.While(&vid) {
-			return ret, nil
-		}
-		if opt.Filter(&vid) {
-			ret = append(ret, vid)
+	return true
+}
+
+// resolveHD returns the HD version of the post unless opt.SD, or the post itself with an error.
+func (c *Client) resolveHD(ctx context.Context, post Post, opt *FeedOpt) (Post, error) {
+	if opt.SD {
+		return post, nil
+	}
+	vidHD, err := c.GetPostContext(ctx, post.VideoId, true)
+	if err != nil {
+		return post, err
+	}
+	// Backends other than Tikwm could answer without a post.
+	if vidHD == nil {
+		return post, fmt.Errorf("post %s: %w", post.ID(), ErrNoData)
+	}
+	return *vidHD, nil
+}
+
+// resolveHDAll resolves HD versions of posts with opt.HDWorkers workers and passes them to emit as they are ready,
+// in the original order if opt.KeepOrder. It stops once emit returns false or ctx is done.
+func (c *Client) resolveHDAll(ctx context.Context, posts []Post, opt *FeedOpt, emit func(post Post, err error) bool) {
+	if opt.SD || opt.HDWorkers <= 1 {
+		for _, post := range posts {
+			if !emit(c.resolveHD(ctx, post, opt)) {
+				return
+			}
 		}
+		return
 	}
 
-	if !feed.HasMore {
-		return ret, nil
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		i    int
+		post Post
+		err  error
 	}
+	jobs := make(chan int)
+	results := make(chan result)
+	// window limits the posts taken by workers, but not emitted yet, so KeepOrder buffers a few of them at most.
+	window := make(chan struct{}, opt.HDWorkers*2)
 
-	deeperRet, err := userFeedUntilInternal(uniqueID, feed.Cursor, opt)
-	if err != nil {
-		return ret, err
+	go func() {
+		defer close(jobs)
+		for i := range posts {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for range opt.HDWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				post, err := c.resolveHD(ctx, posts[i], opt)
+				select {
+				case results <- result{i, post, err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
 	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-	return append(ret, deeperRet...), nil
+	buffered := map[int]result{}
+	next := 0
+	for res := range results {
+		if ctx.Err() != nil {
+			return
+		}
+		if !opt.KeepOrder {
+			<-window
+			if !emit(res.post, res.err) {
+				return
+			}
+			continue
+		}
+
+		buffered[res.i] = res
+		for res, ok := buffered[next]; ok; res, ok = buffered[next] {
+			delete(buffered, next)
+			next++
+			<-window
+			if !emit(res.post, res.err) {
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) userFeedUntilInternal(ctx context.Context, uniqueID string, opt *FeedOpt, checkpoint *feedCheckpoint) ([]Post, error) {
+	cursor, ret := checkpoint.cursor()
+	saved, pages := len(ret), 0
+	err := c.walkUserFeed(ctx, uniqueID, cursor, opt, checkpoint, func(posts []Post, next string) error {
+		ret = append(ret, posts...)
+		if pages++; next != "" && pages%checkpointPages == 0 {
+			checkpoint.fetched(next, ret[saved:])
+			saved = len(ret)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// walkUserFeed calls page with the filtered posts of every page and the cursor of the next one,
+// next is "" for the last page, or when While stops the walk.
+func (c *Client) walkUserFeed(ctx context.Context, uniqueID string, cursor string, opt *FeedOpt, checkpoint *feedCheckpoint, page func(posts []Post, next string) error) error {
+	for {
+		feed, err := c.GetUserFeedRawContext(ctx, uniqueID, c.maxUserFeedCount(), cursor)
+		if err != nil {
+			return err
+		}
+		// Backends other than Tikwm could answer without a page.
+		if feed == nil {
+			return fmt.Errorf("user/posts of %s: %w", uniqueID, ErrNoData)
+		}
+
+		if len(feed.Videos) > c.maxUserFeedCount() {
+			feed.Videos = feed.Videos[:c.maxUserFeedCount()]
+		}
+		posts := []Post{}
+		next := feed.Cursor
+		if !feed.HasMore {
+			next = ""
+		}
+		for _, vid := range feed.Videos {
+			if !opt.While(&vid) {
+				next = ""
+				break
+			}
+			if opt.Filter(&vid) && !checkpoint.isSkipped(&vid) {
+				posts = append(posts, vid)
+			}
+		}
+
+		if err := page(posts, next); err != nil || next == "" {
+			return err
+		}
+		cursor = next
+	}
 }
@@ -0,0 +1,180 @@
+package tt
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HealthTracker tracks error rates and latency of API endpoints and cools the failing ones down, see Client.Mirrors.
+// It could be shared between clients, the zero value is usable.
+type HealthTracker struct {
+	// MaxErrorRate of an endpoint, it's cooled down once its recent error rate reaches it. Defaults to 0.5.
+	MaxErrorRate float64
+	// MinRequests to an endpoint before its error rate is trusted, defaults to 3.
+	MinRequests int
+	// Cooldown of unhealthy endpoints, they aren't requested until it passes unless every endpoint is cooling down.
+	// Defaults to 30 seconds.
+	Cooldown time.Duration
+
+	mu        sync.Mutex
+	endpoints map[string]*EndpointStats
+}
+
+// EndpointStats is the health of an API endpoint, see Client.Stats.
+type EndpointStats struct {
+	URL string
+	// Requests and Failures reported so far, only transient errors are failures (see IsTransient).
+	Requests int
+	Failures int
+	// ErrorRate and Latency are moving averages over the recent requests.
+	ErrorRate float64
+	Latency   time.Duration
+	// CooldownUntil is set while the endpoint is skipped.
+	CooldownUntil time.Time
+}
+
+// Healthy unless it's cooling down.
+func (stats EndpointStats) Healthy() bool {
+	return !time.Now().Before(stats.CooldownUntil)
+}
+
+// healthSmoothing is the weight of the last request in the moving averages.
+const healthSmoothing = 0.2
+
+var defaultHealthTracker = &HealthTracker{}
+
+// Report the outcome of a request to url. Requests cancelled by their context aren't counted.
+func (tracker *HealthTracker) Report(url string, latency time.Duration, err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	failed := 0.0
+	if IsTransient(err) {
+		failed = 1
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	stats := tracker.stats(url)
+	if stats.Requests == 0 {
+		stats.ErrorRate, stats.Latency = failed, latency
+	} else {
+		stats.ErrorRate += (failed - stats.ErrorRate) * healthSmoothing
+		stats.Latency += time.Duration(float64(latency-stats.Latency) * healthSmoothing)
+	}
+	stats.Requests++
+	stats.Failures += int(failed)
+
+	if failed == 1 && stats.Requests >= tracker.minRequests() && stats.ErrorRate >= tracker.maxErrorRate() {
+		stats.CooldownUntil = time.Now().Add(tracker.cooldown())
+	}
+}
+
+// Stats of url, the ones that were never reported are zero.
+func (tracker *HealthTracker) Stats(url string) EndpointStats {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return *tracker.stats(url)
+}
+
+// Order the urls to try: the healthy ones keep their order, the cooling down ones follow
+// sorted by the end of their cooldown.
+func (tracker *HealthTracker) Order(urls []string) []string {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	healthy, cooling := []string{}, []*EndpointStats{}
+	for _, url := range urls {
+		if stats := tracker.stats(url); stats.Healthy() {
+			healthy = append(healthy, url)
+		} else {
+			cooling = append(cooling, stats)
+		}
+	}
+	slices.SortStableFunc(cooling, func(a, b *EndpointStats) int { return a.CooldownUntil.Compare(b.CooldownUntil) })
+	for _, stats := range cooling {
+		healthy = append(healthy, stats.URL)
+	}
+	return healthy
+}
+
+func (tracker *HealthTracker) stats(url string) *EndpointStats {
+	if tracker.endpoints == nil {
+		tracker.endpoints = map[string]*EndpointStats{}
+	}
+	stats, ok := tracker.endpoints[url]
+	if !ok {
+		stats = &EndpointStats{URL: url}
+		tracker.endpoints[url] = stats
+	}
+	return stats
+}
+
+func (tracker *HealthTracker) maxErrorRate() float64 {
+	if tracker.MaxErrorRate > 0 {
+		return tracker.MaxErrorRate
+	}
+	return 0.5
+}
+
+func (tracker *HealthTracker) minRequests() int {
+	if tracker.MinRequests > 0 {
+		return tracker.MinRequests
+	}
+	return 3
+}
+
+func (tracker *HealthTracker) cooldown() time.Duration {
+	if tracker.Cooldown > 0 {
+		return tracker.Cooldown
+	}
+	return time.Second * 30
+}
+
+// Stats of the endpoints of the client in their order, see Client.Mirrors.
+func (c *Client) Stats() []EndpointStats {
+	result := []EndpointStats{}
+	for _, url := range c.endpoints() {
+		result = append(result, c.health().Stats(url))
+	}
+	return result
+}
+
+func (c *Client) endpoints() []string {
+	if len(c.Mirrors) != 0 {
+		return c.Mirrors
+	}
+	return []string{c.url()}
+}
+
+func (c *Client) health() *HealthTracker {
+	if c.Health != nil {
+		return c.Health
+	}
+	return defaultHealthTracker
+}
+
+// endpointRotation picks the endpoint for every attempt of a request: base if it's set, otherwise the healthiest
+// endpoint that wasn't tried yet, so retries go to the next mirror.
+func (c *Client) endpointRotation(base string) func() string {
+	tried := map[string]bool{}
+	return func() string {
+		if base != "" {
+			return base
+		}
+		endpoints := c.health().Order(c.endpoints())
+		if len(tried) >= len(endpoints) {
+			clear(tried)
+		}
+		for _, endpoint := range endpoints {
+			if !tried[endpoint] {
+				tried[endpoint] = true
+				return endpoint
+			}
+		}
+		return endpoints[0]
+	}
+}
@@ -0,0 +1,36 @@
+package tt_test
+
+import (
+	"testing"
+
+	"github.com/heilkit/tt/tt"
+	"github.com/heilkit/tt/tt/tttest"
+)
+
+func TestMirrors(t *testing.T) {
+	down, mirror := newServer(t), newServer(t)
+	down.Close()
+	client := mirror.Client()
+	client.Mirrors = []string{down.URL + "/api", mirror.URL + "/api"}
+	client.Health = &tt.HealthTracker{MinRequests: 1}
+
+	// The retry goes to the mirror, the endpoint that is down cools down.
+	if _, err := client.GetUserDetail("losertron"); err != nil {
+		t.Fatal(err)
+	}
+	stats := client.Stats()
+	if len(stats) != 2 || stats[0].Failures != 1 || stats[0].Healthy() || stats[1].Requests != 1 || !stats[1].Healthy() {
+		t.Fatalf("got %+v", stats)
+	}
+
+	// The next request skips it.
+	if _, err := client.GetPost("7301000000000000001"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := client.Stats(); stats[0].Requests != 1 || stats[1].Requests != 2 || stats[1].Latency <= 0 {
+		t.Errorf("got %+v", stats)
+	}
+	if hits := mirror.Hits(tttest.MethodPost); hits != 1 {
+		t.Errorf("got %d post requests, want 1", hits)
+	}
+}
@@ -0,0 +1,112 @@
+package tt
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// errStopIteration ends walkUserFeed once the loop over UserPosts breaks.
+var errStopIteration = errors.New("stop iteration")
+
+// UserPosts iterates over the user feed newest-first, the next page is fetched only once the loop gets to it,
+// so breaking the loop stops all the work, and there's no goroutine to leak.
+// FeedOpt.Filter, While, SD, HDWorkers, KeepOrder and Checkpoint are respected, OnError, ReturnChan and OldestFirst are ignored:
+// errors are yielded in order instead. A failed page yields a zero Post with the error and ends the iteration,
+// a failed HD lookup yields the SD post with the error, and the iteration goes on.
+func UserPosts(ctx context.Context, uniqueID string, opts ...FeedOpt) iter.Seq2[Post, error] {
+	return DefaultClient.UserPosts(ctx, uniqueID, opts...)
+}
+
+func (c *Client) UserPosts(ctx context.Context, uniqueID string, opts ...FeedOpt) iter.Seq2[Post, error] {
+	return func(yield func(Post, error) bool) {
+		var opt *FeedOpt = nil
+		if len(opts) != 0 {
+			copied := opts[0]
+			opt = &copied
+		}
+		opt = opt.Defaults()
+
+		checkpoint, err := loadFeedCheckpoint(uniqueID, opt)
+		if err != nil {
+			yield(Post{}, err)
+			return
+		}
+		var saveErr error
+		checkpoint.onError = func(err error) {
+			saveErr = err
+		}
+		// yieldSaveErr passes checkpoint errors to the loop right after they happen.
+		yieldSaveErr := func() bool {
+			if saveErr == nil {
+				return true
+			}
+			err := saveErr
+			saveErr = nil
+			return yield(Post{}, err)
+		}
+
+		stopped := false
+		emit := func(post Post, err error) bool {
+			if ctx.Err() != nil {
+				yield(Post{}, ctx.Err())
+				stopped = true
+				return false
+			}
+			stopped = !yield(post, err)
+			return !stopped
+		}
+
+		cursor, pending := checkpoint.cursor()
+		if c.resolveHDAll(ctx, pending, opt, emit); stopped {
+			return
+		}
+
+		err = c.walkUserFeed(ctx, uniqueID, cursor, opt, checkpoint, func(posts []Post, next string) error {
+			checkpoint.fetched(next, posts)
+			if !yieldSaveErr() {
+				return errStopIteration
+			}
+			if c.resolveHDAll(ctx, posts, opt, emit); stopped {
+				return errStopIteration
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopIteration) {
+			yield(Post{}, err)
+		}
+	}
+}
+
+// UserSearchResults iterates over the users found by keyword, the next page is fetched only once the loop gets to it.
+// A failed page yields a zero SearchedUser with the error and ends the iteration.
+func UserSearchResults(ctx context.Context, keyword string) iter.Seq2[SearchedUser, error] {
+	return DefaultClient.UserSearchResults(ctx, keyword)
+}
+
+func (c *Client) UserSearchResults(ctx context.Context, keyword string) iter.Seq2[SearchedUser, error] {
+	return func(yield func(SearchedUser, error) bool) {
+		cursor := ""
+		for {
+			page, err := c.SearchUsersContext(ctx, keyword, cursor)
+			if err != nil {
+				yield(SearchedUser{}, err)
+				return
+			}
+			if page == nil {
+				return
+			}
+			for _, user := range page.Users() {
+				if !yield(user, nil) {
+					return
+				}
+			}
+			// A cursor that doesn't move would repeat the page forever.
+			next := page.Cursor.String()
+			if !page.HasMore || len(page.UserList) == 0 || next == cursor {
+				return
+			}
+			cursor = next
+		}
+	}
+}
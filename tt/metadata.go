@@ -0,0 +1,127 @@
+package tt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// maxCoverSize of the cover art to embed.
+const maxCoverSize = 8 << 20
+
+// EmbedMetadataMP4 writes title, author, create date, source URL and cover art of the post into the MP4 file,
+// see DownloadOpt.EmbedMetadata. It's a pure-Go MP4 writer, the cover is skipped if it can't be fetched or it's not JPEG or PNG.
+// The cover is fetched with the client of the download, DefaultClient outside of downloads.
+func EmbedMetadataMP4(ctx context.Context, post *Post, filename string) error {
+	return clientOf(ctx).EmbedMetadataMP4(ctx, post, filename)
+}
+
+// EmbedMetadataMP4 fetches the cover with the client's HTTP client.
+func (c *Client) EmbedMetadataMP4(ctx context.Context, post *Post, filename string) error {
+	tags := []mp4Tag{}
+	for _, tag := range post.metadata() {
+		tags = append(tags, mp4Tag{typ: tag.atom, dataType: 1, value: []byte(tag.value)})
+	}
+	if cover, err := c.fetchCover(ctx, post); err == nil {
+		if dataType := mp4ImageType(cover); dataType != 0 {
+			tags = append(tags, mp4Tag{typ: "covr", dataType: dataType, value: cover})
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if err := writeMP4Tags(filename, tags); err != nil {
+		return fmt.Errorf("embed metadata to %s: %w", filename, err)
+	}
+	return nil
+}
+
+// EmbedMetadataWithFfmpeg is EmbedMetadataMP4 using the ffmpeg binary, by default the one in PATH.
+// The cover is fetched the same way, and skipped if it can't be.
+func EmbedMetadataWithFfmpeg(ffmpeg ...string) func(ctx context.Context, post *Post, filename string) error {
+	ffmpeg_ := "ffmpeg"
+	if len(ffmpeg) != 0 {
+		ffmpeg_ = ffmpeg[0]
+	}
+
+	return func(ctx context.Context, post *Post, filename string) error {
+		tmp := filepath.Join(filepath.Dir(filename), ".tmp."+filepath.Base(filename))
+		defer os.Remove(tmp)
+
+		args := []string{"-y", "-loglevel", "error", "-i", filename}
+		if cover, err := clientOf(ctx).fetchCover(ctx, post); err == nil {
+			coverFile := filepath.Join(filepath.Dir(filename), ".cover."+filepath.Base(filename)+".jpg")
+			defer os.Remove(coverFile)
+			if err := os.WriteFile(coverFile, cover, 0644); err == nil {
+				args = append(args, "-i", coverFile, "-map", "0", "-map", "1", "-disposition:v:1", "attached_pic")
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		args = append(args, "-c", "copy")
+		for _, tag := range post.metadata() {
+			args = append(args, "-metadata", tag.ffmpeg+"="+tag.value)
+		}
+		args = append(args, "-f", "mp4", tmp)
+
+		out, err := exec.CommandContext(ctx, ffmpeg_, args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("err: %s,\n%s", err.Error(), string(out))
+		}
+		return os.Rename(tmp, filename)
+	}
+}
+
+type metadataTag struct {
+	atom   string
+	ffmpeg string
+	value  string
+}
+
+// metadata of the post to embed, empty values are skipped.
+func (post *Post) metadata() []metadataTag {
+	tags := []metadataTag{
+		{"\xa9nam", "title", post.Title},
+		{"\xa9ART", "artist", post.Author.UniqueId},
+		{"\xa9day", "date", time.Unix(post.CreateTime, 0).UTC().Format(time.RFC3339)},
+		{"\xa9cmt", "comment", post.URL()},
+	}
+	result := []metadataTag{}
+	for _, tag := range tags {
+		if tag.value != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+func (c *Client) fetchCover(ctx context.Context, post *Post) ([]byte, error) {
+	if post.Cover == "" {
+		return nil, fmt.Errorf("no cover")
+	}
+	return c.fetch(ctx, post.Cover, maxCoverSize)
+}
+
+// fetch a small file to memory, up to limit bytes.
+func (c *Client) fetch(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Method: url}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
@@ -0,0 +1,328 @@
+package tt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// mp4Tag is an item of the iTunes-style metadata list moov/udta/meta/ilst.
+type mp4Tag struct {
+	typ string
+	// dataType is the well-known type of the value: 1 for UTF-8, 13 for JPEG, 14 for PNG.
+	dataType uint32
+	value    []byte
+}
+
+// mp4Atom is a box with its header, data of the children atoms points into the data of the parent.
+type mp4Atom struct {
+	typ    string
+	data   []byte
+	header int
+}
+
+func (atom mp4Atom) body() []byte {
+	return atom.data[atom.header:]
+}
+
+// writeMP4Tags sets the tags of the file, keeping the rest of its metadata. The file is rewritten atomically,
+// chunk offsets are adjusted when moov grows in front of the media data.
+func writeMP4Tags(filename string, tags []mp4Tag) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	type topLevel struct {
+		typ          string
+		offset, size int64
+	}
+	boxes := []topLevel{}
+	moovIndex := -1
+	for offset := int64(0); offset < stat.Size(); {
+		header := make([]byte, 16)
+		n, err := file.ReadAt(header, offset)
+		if n < 8 {
+			return fmt.Errorf("mp4: truncated box at %d: %w", offset, err)
+		}
+		typ, size, headerSize, err := parseAtomHeader(header[:n], stat.Size()-offset)
+		if err != nil {
+			return fmt.Errorf("mp4: box at %d: %w", offset, err)
+		}
+		if size < int64(headerSize) || offset+size > stat.Size() {
+			return fmt.Errorf("mp4: box %q at %d has bad size %d", typ, offset, size)
+		}
+		if typ == "moov" {
+			moovIndex = len(boxes)
+		}
+		boxes = append(boxes, topLevel{typ, offset, size})
+		offset += size
+	}
+	if moovIndex < 0 {
+		return fmt.Errorf("mp4: no moov box")
+	}
+
+	moovBox := boxes[moovIndex]
+	moovData := make([]byte, moovBox.size)
+	if _, err := file.ReadAt(moovData, moovBox.offset); err != nil {
+		return fmt.Errorf("mp4: moov: %w", err)
+	}
+	moov, err := parseAtom(moovData)
+	if err != nil {
+		return fmt.Errorf("mp4: moov: %w", err)
+	}
+	newMoov, err := setMoovTags(moov, tags)
+	if err != nil {
+		return fmt.Errorf("mp4: %w", err)
+	}
+	// Media data behind moov moves with its growth.
+	if delta := int64(len(newMoov)) - moovBox.size; delta != 0 {
+		if err := shiftChunkOffsets(newMoov, moovBox.offset+moovBox.size, delta); err != nil {
+			return fmt.Errorf("mp4: %w", err)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	for _, box := range boxes {
+		if box.typ == "moov" {
+			_, err = tmp.Write(newMoov)
+		} else {
+			_, err = io.Copy(tmp, io.NewSectionReader(file, box.offset, box.size))
+		}
+		if err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	_ = os.Chmod(tmp.Name(), stat.Mode())
+	return os.Rename(tmp.Name(), filename)
+}
+
+// parseAtomHeader of a box, remaining is the space left for it in the parent.
+func parseAtomHeader(data []byte, remaining int64) (typ string, size int64, headerSize int, err error) {
+	if len(data) < 8 {
+		return "", 0, 0, fmt.Errorf("truncated header")
+	}
+	size = int64(binary.BigEndian.Uint32(data))
+	typ = string(data[4:8])
+	headerSize = 8
+	switch size {
+	case 0:
+		size = remaining
+	case 1:
+		if len(data) < 16 {
+			return "", 0, 0, fmt.Errorf("truncated header of %q", typ)
+		}
+		size = int64(binary.BigEndian.Uint64(data[8:]))
+		headerSize = 16
+	}
+	return typ, size, headerSize, nil
+}
+
+func parseAtom(data []byte) (mp4Atom, error) {
+	typ, size, header, err := parseAtomHeader(data, int64(len(data)))
+	if err != nil {
+		return mp4Atom{}, err
+	}
+	if size < int64(header) || size > int64(len(data)) {
+		return mp4Atom{}, fmt.Errorf("box %q has bad size %d", typ, size)
+	}
+	return mp4Atom{typ: typ, data: data[:size], header: header}, nil
+}
+
+func parseAtoms(data []byte) ([]mp4Atom, error) {
+	atoms := []mp4Atom{}
+	for len(data) != 0 {
+		atom, err := parseAtom(data)
+		if err != nil {
+			return nil, err
+		}
+		atoms = append(atoms, atom)
+		data = data[len(atom.data):]
+	}
+	return atoms, nil
+}
+
+func findAtom(atoms []mp4Atom, typ string) (mp4Atom, bool) {
+	for _, atom := range atoms {
+		if atom.typ == typ {
+			return atom, true
+		}
+	}
+	return mp4Atom{}, false
+}
+
+// appendAtom encodes a box of the payloads.
+func appendAtom(dst []byte, typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, part := range payload {
+		size += len(part)
+	}
+	dst = binary.BigEndian.AppendUint32(dst, uint32(size))
+	dst = append(dst, typ...)
+	for _, part := range payload {
+		dst = append(dst, part...)
+	}
+	return dst
+}
+
+// setMoovTags returns moov with tags in moov/udta/meta/ilst, replacing the items of the same type.
+func setMoovTags(moov mp4Atom, tags []mp4Tag) ([]byte, error) {
+	children, err := parseAtoms(moov.body())
+	if err != nil {
+		return nil, fmt.Errorf("moov: %w", err)
+	}
+
+	var udtaChildren []mp4Atom
+	if udta, ok := findAtom(children, "udta"); ok {
+		if udtaChildren, err = parseAtoms(udta.body()); err != nil {
+			return nil, fmt.Errorf("udta: %w", err)
+		}
+	}
+	var metaChildren []mp4Atom
+	if meta, ok := findAtom(udtaChildren, "meta"); ok {
+		body := meta.body()
+		// ISO meta is a full box with version and flags, QuickTime one is not.
+		if len(body) >= 8 && string(body[4:8]) != "hdlr" {
+			body = body[4:]
+		}
+		if metaChildren, err = parseAtoms(body); err != nil {
+			return nil, fmt.Errorf("meta: %w", err)
+		}
+	}
+	var items []mp4Atom
+	if ilst, ok := findAtom(metaChildren, "ilst"); ok {
+		if items, err = parseAtoms(ilst.body()); err != nil {
+			return nil, fmt.Errorf("ilst: %w", err)
+		}
+	}
+
+	ilst := []byte{}
+	for _, item := range items {
+		if !slices.ContainsFunc(tags, func(tag mp4Tag) bool { return tag.typ == item.typ }) {
+			ilst = append(ilst, item.data...)
+		}
+	}
+	for _, tag := range tags {
+		ilst = appendAtom(ilst, tag.typ, appendAtom(nil, "data", binary.BigEndian.AppendUint32(nil, tag.dataType), make([]byte, 4), tag.value))
+	}
+
+	hdlr := []byte(nil)
+	if atom, ok := findAtom(metaChildren, "hdlr"); ok {
+		hdlr = atom.data
+	} else {
+		hdlr = appendAtom(nil, "hdlr", make([]byte, 8), []byte("mdirappl"), make([]byte, 9))
+	}
+	meta := []byte{}
+	for _, atom := range metaChildren {
+		if atom.typ != "hdlr" && atom.typ != "ilst" {
+			meta = append(meta, atom.data...)
+		}
+	}
+	meta = appendAtom(nil, "meta", make([]byte, 4), hdlr, meta, appendAtom(nil, "ilst", ilst))
+
+	udta := []byte{}
+	for _, atom := range udtaChildren {
+		if atom.typ != "meta" {
+			udta = append(udta, atom.data...)
+		}
+	}
+	udta = appendAtom(nil, "udta", udta, meta)
+
+	body := []byte{}
+	for _, atom := range children {
+		if atom.typ != "udta" {
+			body = append(body, atom.data...)
+		}
+	}
+	return appendAtom(nil, "moov", body, udta), nil
+}
+
+// shiftChunkOffsets of the tracks in moov that point at or after from by delta, in place.
+func shiftChunkOffsets(moov []byte, from int64, delta int64) error {
+	atom, err := parseAtom(moov)
+	if err != nil {
+		return err
+	}
+	return walkAtoms(atom, func(atom mp4Atom) error {
+		body := atom.body()
+		if len(body) < 8 {
+			return fmt.Errorf("%s: truncated", atom.typ)
+		}
+		count := int(binary.BigEndian.Uint32(body[4:]))
+		entries := body[8:]
+
+		switch atom.typ {
+		case "stco":
+			if len(entries) < count*4 {
+				return fmt.Errorf("stco: truncated")
+			}
+			for i := 0; i < count; i++ {
+				offset := int64(binary.BigEndian.Uint32(entries[i*4:]))
+				if offset < from {
+					continue
+				}
+				if offset+delta > 0xffffffff || offset+delta < 0 {
+					return fmt.Errorf("stco: offset %d doesn't fit 32 bits", offset+delta)
+				}
+				binary.BigEndian.PutUint32(entries[i*4:], uint32(offset+delta))
+			}
+		case "co64":
+			if len(entries) < count*8 {
+				return fmt.Errorf("co64: truncated")
+			}
+			for i := 0; i < count; i++ {
+				if offset := int64(binary.BigEndian.Uint64(entries[i*8:])); offset >= from {
+					binary.BigEndian.PutUint64(entries[i*8:], uint64(offset+delta))
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// walkAtoms calls chunkOffsets for the stco and co64 boxes of every track.
+func walkAtoms(atom mp4Atom, chunkOffsets func(atom mp4Atom) error) error {
+	switch atom.typ {
+	case "stco", "co64":
+		return chunkOffsets(atom)
+	case "moov", "trak", "mdia", "minf", "stbl":
+		children, err := parseAtoms(atom.body())
+		if err != nil {
+			return fmt.Errorf("%s: %w", atom.typ, err)
+		}
+		for _, child := range children {
+			if err := walkAtoms(child, chunkOffsets); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mp4ImageType is the well-known type of covr data, 0 for formats it doesn't support.
+func mp4ImageType(data []byte) uint32 {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}):
+		return 13
+	case bytes.HasPrefix(data, []byte("\x89PNG")):
+		return 14
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package tt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmbedMetadataMP4(t *testing.T) {
+	cover := []byte{0xff, 0xd8, 0xff, 0xe0, 'j', 'p', 'e', 'g'}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write(cover) }))
+	defer server.Close()
+
+	// ftyp, moov with a single chunk offset, then mdat pointing at the marker.
+	ftyp := appendAtom(nil, "ftyp", []byte("isom\x00\x00\x02\x00isommp41"))
+	stco := func(offset uint32) []byte {
+		return appendAtom(nil, "stco", make([]byte, 4), binary.BigEndian.AppendUint32(nil, 1), binary.BigEndian.AppendUint32(nil, offset))
+	}
+	moov := func(offset uint32) []byte {
+		stbl := appendAtom(nil, "stbl", stco(offset))
+		return appendAtom(nil, "moov", appendAtom(nil, "mvhd", make([]byte, 100)),
+			appendAtom(nil, "trak", appendAtom(nil, "mdia", appendAtom(nil, "minf", stbl))))
+	}
+	marker := []byte("MARKER")
+	offset := uint32(len(ftyp) + len(moov(0)) + 8)
+	data := append(append(ftyp, moov(offset)...), appendAtom(nil, "mdat", marker)...)
+
+	filename := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	post := &Post{VideoId: "7179438804418268417", Title: "first", Cover: server.URL}
+	post.Author.UniqueId = "canthinky"
+	client := &Client{HTTPClient: server.Client()}
+	for _, title := range []string{"first", "second"} {
+		post.Title = title
+		if err := client.EmbedMetadataMP4(context.Background(), post, filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	atoms, err := parseAtoms(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moovAtom, _ := findAtom(atoms, "moov")
+	tags := map[string][]byte{}
+	err = walkMoov(moovAtom, func(atom mp4Atom) {
+		switch atom.typ {
+		case "stco":
+			offset := binary.BigEndian.Uint32(atom.body()[8:])
+			if got := data[offset : int(offset)+len(marker)]; !bytes.Equal(got, marker) {
+				t.Errorf("chunk offset points at %q", got)
+			}
+		case "\xa9nam", "\xa9ART", "\xa9cmt", "covr":
+			if _, ok := tags[atom.typ]; ok {
+				t.Errorf("duplicate %q", atom.typ)
+			}
+			tags[atom.typ] = atom.body()[16:]
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for typ, want := range map[string]string{"\xa9nam": "second", "\xa9ART": "canthinky", "\xa9cmt": post.URL(), "covr": string(cover)} {
+		if got := string(tags[typ]); got != want {
+			t.Errorf("%q: got %q, want %q", typ, got, want)
+		}
+	}
+}
+
+// walkMoov visits the tracks and the metadata items.
+func walkMoov(atom mp4Atom, visit func(atom mp4Atom)) error {
+	visit(atom)
+	body := atom.body()
+	switch atom.typ {
+	case "meta":
+		body = body[4:]
+	case "moov", "trak", "mdia", "minf", "stbl", "udta", "ilst":
+	default:
+		return nil
+	}
+	children, err := parseAtoms(body)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := walkMoov(child, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
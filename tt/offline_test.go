@@ -0,0 +1,414 @@
+package tt_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heilkit/tt/tt"
+	"github.com/heilkit/tt/tt/tttest"
+)
+
+func newServer(t *testing.T) *tttest.Server {
+	server := tttest.NewServer()
+	t.Cleanup(server.Close)
+	if err := server.LoadFixtures("tttest/testdata"); err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func TestGetUserFeedOffline(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	client.MaxUserFeedCount = 3
+	// A rate limit and a broken page are retried.
+	server.Script(tttest.MethodUserFeed, tttest.RateLimited(), tttest.Status(http.StatusBadGateway))
+
+	posts, err := client.GetUserFeedAwait("losertron", tt.FeedOpt{HDWorkers: 2, KeepOrder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for _, post := range posts {
+		ids = append(ids, post.ID())
+		if post.IsVideo() && post.Hdplay == "" {
+			t.Errorf("%s: HD is not resolved", post.ID())
+		}
+	}
+	want := []string{"7301000000000000001", "7301000000000000002", "7301000000000000003", "7301000000000000004"}
+	if !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+	// 2 failures and 2 pages.
+	if hits := server.Hits(tttest.MethodUserFeed); hits != 4 {
+		t.Errorf("got %d feed requests, want 4", hits)
+	}
+
+	if _, err := client.GetUserFeedAwait("nobody"); !errors.Is(err, tt.ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetUserFeedStreamOffline(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	client.MaxUserFeedCount = 2
+
+	postChan, progress, err := client.GetUserFeedStream("losertron", tt.FeedOpt{SD: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first page comes before the second one is fetched.
+	ids := []string{(<-postChan).ID()}
+	if count, final := progress.Expected(); count != 2 || final || server.Hits(tttest.MethodUserFeed) != 1 {
+		t.Errorf("got %d expected posts (final: %v) after %d pages, want 2 after the first one", count, final, server.Hits(tttest.MethodUserFeed))
+	}
+	for post := range postChan {
+		ids = append(ids, post.ID())
+	}
+	want := []string{"7301000000000000004", "7301000000000000003", "7301000000000000002", "7301000000000000001"}
+	if !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+	if count, final := progress.Expected(); count != 4 || !final || progress.Err() != nil {
+		t.Errorf("got %d expected posts (final: %v, err: %v), want 4", count, final, progress.Err())
+	}
+
+	// OldestFirst fetches the whole feed first.
+	postChan, progress, err = client.GetUserFeedStream("losertron", tt.FeedOpt{SD: true, OldestFirst: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids = []string{(<-postChan).ID()}
+	if count, final := progress.Expected(); count != 4 || !final {
+		t.Errorf("got %d expected posts (final: %v), want 4", count, final)
+	}
+	for post := range postChan {
+		ids = append(ids, post.ID())
+	}
+	slices.Reverse(want)
+	if !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+}
+
+func TestPostDownloadOffline(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	dir := t.TempDir()
+	opt := &tt.DownloadOpt{Directory: dir, Timeout: time.Nanosecond, TimeoutOnError: time.Nanosecond, Retries: 2}
+
+	// The first transfer fails and gets retried.
+	server.Script("cdn", tttest.Status(http.StatusServiceUnavailable))
+	post, filenames, err := client.Download("https://www.tiktok.com/@losertron/video/7301000000000000004", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filenames) != 1 {
+		t.Fatalf("got %v, want a single file", filenames)
+	}
+	if data, err := os.ReadFile(filenames[0]); err != nil || !bytes.Equal(data, server.File(post.Hdplay)) {
+		t.Errorf("%s doesn't match the HD source: %v", filenames[0], err)
+	}
+
+	album := server.Post("7301000000000000003")
+	filenames, err = client.DownloadPost(album, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filenames) != 3 || filepath.Dir(filenames[0]) != dir {
+		t.Errorf("got %v, want 3 images in %s", filenames, dir)
+	}
+
+	// A template without {index} still gives every image its own file.
+	format, err := tt.FilenameTemplate("{id}.{ext}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed := *opt
+	indexed.Directory, indexed.FilenameFormat = t.TempDir(), format
+	filenames, err = client.DownloadPost(album, &indexed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"7301000000000000003_1.jpg", "7301000000000000003_2.jpg", "7301000000000000003_3.jpg"}
+	for i, filename := range filenames {
+		if _, err := os.Stat(filename); err != nil || i >= len(want) || filepath.Base(filename) != want[i] {
+			t.Errorf("got %v, want %v: %v", filenames, want, err)
+			break
+		}
+	}
+
+	// Slow transfers stop once the context is done.
+	server.BytesPerSecond = 1000
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*300)
+	defer cancel()
+	opt.Directory = t.TempDir()
+	_, err = client.DownloadPostContext(ctx, server.Post("7301000000000000002"), opt)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestSearchUsersOffline(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	client.MaxUserFeedCount = 2
+	for _, uniqueID := range []string{"loser.fan", "winner", "loser.cat"} {
+		user := tt.UserDetail{}
+		user.User.UniqueId = uniqueID
+		server.AddUser(user)
+	}
+
+	found := []string{}
+	for user, err := range client.UserSearchResults(context.Background(), "LOSER") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		found = append(found, user.UniqueId)
+	}
+	want := []string{"loser.cat", "loser.fan", "losertron"}
+	if !slices.Equal(found, want) {
+		t.Errorf("got %v, want %v", found, want)
+	}
+	if hits := server.Hits(tttest.MethodUserSearch); hits != 2 {
+		t.Errorf("got %d search requests, want 2", hits)
+	}
+
+	page, err := client.SearchUsers("losertron", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users := page.Users(); len(users) != 1 || users[0].FollowerCount != 3400 || page.HasMore {
+		t.Errorf("got %+v", page)
+	}
+}
+
+func TestNullPostOffline(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	client.Retry = &tt.RetryPolicy{MaxAttempts: 1}
+	// tikwm answers "success" without the post sometimes.
+	server.Script(tttest.MethodPost, tttest.Response{Body: `{"code":0,"msg":"success","data":null}`})
+
+	errs := []error{}
+	posts, err := client.GetUserFeedAwait("losertron", tt.FeedOpt{HDWorkers: 2, OnError: func(err error) { errs = append(errs, err) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 4 || len(errs) != 1 || !errors.Is(errs[0], tt.ErrNoData) {
+		t.Errorf("got %d posts and %v, want 4 posts and ErrNoData", len(posts), errs)
+	}
+
+	server.Script(tttest.MethodPost, tttest.Response{Body: `{"code":0,"msg":"success","data":null}`})
+	for post, err := range client.UserPosts(context.Background(), "losertron") {
+		if !errors.Is(err, tt.ErrNoData) || post.ID() != "7301000000000000004" {
+			t.Errorf("got %s and %v, want the SD post with ErrNoData", post.ID(), err)
+		}
+		break
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := tt.NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cache := range []tt.Cache{tt.NewMemoryCache(8), disk} {
+		server := newServer(t)
+		client := server.Client()
+		client.Retry = &tt.RetryPolicy{MaxAttempts: 1}
+		client.Cache = cache
+		// The answer without the post isn't cached.
+		server.Script(tttest.MethodPost, tttest.Response{Body: `{"code":0,"msg":"success","data":null}`})
+
+		if _, err := client.GetPost("7301000000000000001"); !errors.Is(err, tt.ErrNoData) {
+			t.Errorf("got %v, want ErrNoData", err)
+		}
+		for range 2 {
+			if _, err := client.GetPost("7301000000000000001"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if hits := server.Hits(tttest.MethodPost); hits != 2 {
+			t.Errorf("%T: got %d requests, want 2", cache, hits)
+		}
+	}
+
+	// Files of the DiskCache survive restarts.
+	server := newServer(t)
+	client := server.Client()
+	if client.Cache, err = tt.NewDiskCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	if post, err := client.GetPost("7301000000000000001"); err != nil || post.ID() != "7301000000000000001" {
+		t.Fatalf("got %v", err)
+	}
+	if hits := server.Hits(tttest.MethodPost); hits != 0 {
+		t.Errorf("got %d requests after a restart, want 0", hits)
+	}
+}
+
+// countingTransport counts the requests of a client.
+type countingTransport struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (transport *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport.mu.Lock()
+	transport.requests = append(transport.requests, req.URL.Path)
+	transport.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestPackAlbumOffline(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	transport := &countingTransport{}
+	client.Transport = transport
+	opt := &tt.DownloadOpt{Directory: t.TempDir(), Timeout: time.Nanosecond, Retries: 1, PostProcessors: []tt.PostProcessor{tt.PackAlbum("zip")}}
+
+	album := server.Post("7301000000000000003")
+	filenames, err := client.DownloadPost(album, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filenames) != 1 || filepath.Ext(filenames[0]) != ".zip" {
+		t.Errorf("got %v, want a single archive", filenames)
+	}
+	// The music isn't downloaded, so it's fetched by the client of the download.
+	if !slices.Contains(transport.requests, "/cdn/7301000000000000003/music.mp3") {
+		t.Errorf("the music isn't fetched with the client: %v", transport.requests)
+	}
+}
+
+func TestUserPostsOffline(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	client.MaxUserFeedCount = 1
+
+	// Breaking the loop stops fetching the pages.
+	for range client.UserPosts(context.Background(), "losertron", tt.FeedOpt{SD: true}) {
+		break
+	}
+	if hits := server.Hits(tttest.MethodUserFeed); hits != 1 {
+		t.Errorf("got %d feed requests, want 1", hits)
+	}
+
+	// Errors come in order: a failed HD lookup with its SD post, then a failed page ends the iteration.
+	client.MaxUserFeedCount = 2
+	server.Script(tttest.MethodPost, tttest.NotFound())
+	server.Script(tttest.MethodUserFeed, tttest.Response{}, tttest.NotFound())
+	type item struct {
+		id  string
+		err bool
+	}
+	got := []item{}
+	for post, err := range client.UserPosts(context.Background(), "losertron") {
+		got = append(got, item{post.ID(), err != nil})
+	}
+	want := []item{{"7301000000000000004", true}, {"7301000000000000003", false}, {"", true}}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestQualityOffline(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	variants := []tt.Variant{}
+	opt := &tt.DownloadOpt{Directory: t.TempDir(), Timeout: time.Nanosecond, Retries: 1, Fallback: tt.FallbackToSD,
+		OnDownloaded: func(file tt.DownloadedFile) { variants = append(variants, file.Variant) }}
+
+	// The HD source is gone, the SD one is reported.
+	post := server.Post("7301000000000000004")
+	server.RemoveFile(post.Hdplay)
+	if _, err := client.DownloadPost(post, opt); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(variants, []tt.Variant{tt.VariantSD}) {
+		t.Errorf("got %v, want SD", variants)
+	}
+
+	// Only the watermarked source is left.
+	watermarked := *post
+	watermarked.Hdplay, watermarked.Play = "", ""
+	opt.Quality = &tt.QualityPolicy{AllowWatermark: false}
+	if _, err := client.DownloadPost(&watermarked, opt); !errors.Is(err, tt.ErrWatermarked) {
+		t.Errorf("got %v, want ErrWatermarked", err)
+	}
+	variants = nil
+	opt.Quality.AllowWatermark = true
+	if _, err := client.DownloadPost(&watermarked, opt); err != nil || !slices.Equal(variants, []tt.Variant{tt.VariantWatermarked}) {
+		t.Errorf("got %v, want the watermarked video: %v", variants, err)
+	}
+}
+
+// fakeFfmpeg fails unless every input is a local file, and creates the output, its last argument.
+func fakeFfmpeg(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	script := `#!/bin/sh
+previous=""
+for arg; do
+	if [ "$previous" = "-i" ] && [ ! -f "$arg" ]; then echo "not a file: $arg" >&2; exit 1; fi
+	previous="$arg"
+done
+touch "$previous"
+`
+	if err := os.WriteFile(ffmpeg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return ffmpeg
+}
+
+func TestFfmpegOffline(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	ffmpeg := fakeFfmpeg(t)
+	opt := &tt.DownloadOpt{Directory: t.TempDir(), Timeout: time.Nanosecond, EmbedMetadata: tt.EmbedMetadataWithFfmpeg(ffmpeg)}
+
+	// The cover is fetched by the client, and skipped once it's gone.
+	post := server.Post("7301000000000000004")
+	hits := server.Hits("cdn")
+	if _, err := client.DownloadPost(post, opt); err != nil {
+		t.Fatal(err)
+	}
+	if hits := server.Hits("cdn") - hits; hits != 2 {
+		t.Errorf("got %d transfers, want the video and the cover", hits)
+	}
+	server.RemoveFile(post.Cover)
+	opt.Directory = t.TempDir()
+	if _, err := client.DownloadPost(post, opt); err != nil {
+		t.Error(err)
+	}
+
+	// The music of the slideshow is fetched by the client, as it's not downloaded.
+	slideshow := &tt.DownloadOpt{Directory: t.TempDir(), Timeout: time.Nanosecond,
+		PostProcessors: []tt.PostProcessor{tt.Slideshow(&tt.AlbumOpt{Ffmpeg: ffmpeg})}}
+	hits = server.Hits("cdn")
+	filenames, err := client.DownloadPost(server.Post("7301000000000000003"), slideshow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filenames) != 1 || filepath.Ext(filenames[0]) != ".mp4" {
+		t.Errorf("got %v, want the slideshow", filenames)
+	}
+	if hits := server.Hits("cdn") - hits; hits != 4 {
+		t.Errorf("got %d transfers, want 3 images and the music", hits)
+	}
+}
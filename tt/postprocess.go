@@ -0,0 +1,166 @@
+package tt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// PostProcessor runs after the files of the post are downloaded, see DownloadOpt.PostProcessors.
+// It returns the filenames for the next step, so it can rename, add or remove files, an error fails the download.
+type PostProcessor func(ctx context.Context, post *Post, filenames []string, log *slog.Logger) ([]string, error)
+
+// postProcess runs the chain over the downloaded files.
+func (opts *DownloadOpt) postProcess(ctx context.Context, post *Post, filenames []string) ([]string, error) {
+	processors := opts.PostProcessors
+	if opts.EmbedMetadata != nil {
+		processors = append([]PostProcessor{EmbedWith(opts.EmbedMetadata)}, processors...)
+	}
+
+	for i, processor := range processors {
+		if ctx.Err() != nil {
+			return filenames, ctx.Err()
+		}
+		result, err := processor(ctx, post, filenames, opts.Log)
+		if err != nil {
+			return filenames, fmt.Errorf("post processor %d: %w", i, err)
+		}
+		filenames = result
+	}
+	return filenames, nil
+}
+
+// EachFile makes a PostProcessor of a step for a single file, it returns the files the file became.
+func EachFile(step func(ctx context.Context, post *Post, filename string, log *slog.Logger) ([]string, error)) PostProcessor {
+	return func(ctx context.Context, post *Post, filenames []string, log *slog.Logger) ([]string, error) {
+		result := []string{}
+		for _, filename := range filenames {
+			files, err := step(ctx, post, filename, log)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, files...)
+		}
+		return result, nil
+	}
+}
+
+// EmbedWith runs embed for the MP4 files, i.e. EmbedWith(tt.EmbedMetadataMP4), see DownloadOpt.EmbedMetadata.
+func EmbedWith(embed func(ctx context.Context, post *Post, filename string) error) PostProcessor {
+	return EachFile(func(ctx context.Context, post *Post, filename string, log *slog.Logger) ([]string, error) {
+		if filepath.Ext(filename) == ".mp4" {
+			if err := embed(ctx, post, filename); err != nil {
+				return nil, err
+			}
+		}
+		return []string{filename}, nil
+	})
+}
+
+// HashSHA256 writes <file>.sha256 next to every file, in the format of sha256sum, and adds them to the files.
+func HashSHA256() PostProcessor {
+	return func(ctx context.Context, post *Post, filenames []string, log *slog.Logger) ([]string, error) {
+		result := append([]string{}, filenames...)
+		for _, filename := range filenames {
+			sum, err := hashFile(filename)
+			if err != nil {
+				return nil, fmt.Errorf("sha256: %w", err)
+			}
+			line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(filename))
+			if err := os.WriteFile(filename+".sha256", []byte(line), 0644); err != nil {
+				return nil, fmt.Errorf("sha256: %w", err)
+			}
+			log.Debug("Hashed", "file", filename, "sha256", sum)
+			result = append(result, filename+".sha256")
+		}
+		return result, nil
+	}
+}
+
+func hashFile(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// MoveTo moves the files to the directory, creating it if needed.
+func MoveTo(directory string) PostProcessor {
+	return EachFile(func(ctx context.Context, post *Post, filename string, log *slog.Logger) ([]string, error) {
+		if err := os.MkdirAll(directory, 0755); err != nil {
+			return nil, fmt.Errorf("move: %w", err)
+		}
+		target := filepath.Join(directory, filepath.Base(filename))
+		if err := moveFile(filename, target); err != nil {
+			return nil, fmt.Errorf("move: %w", err)
+		}
+		log.Debug("Moved", "file", filename, "to", target)
+		return []string{target}, nil
+	})
+}
+
+// moveFile renames, copying the file if it's on another device.
+func moveFile(from string, to string) error {
+	err := os.Rename(from, to)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		_ = target.Close()
+		_ = os.Remove(to)
+		return err
+	}
+	if err := target.Close(); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+// Exec runs the command for every file, "{}" in the args is replaced with the filename,
+// which is appended to the args if there's no "{}". A failing command fails the download.
+func Exec(name string, args ...string) PostProcessor {
+	return EachFile(func(ctx context.Context, post *Post, filename string, log *slog.Logger) ([]string, error) {
+		replaced := false
+		cmdArgs := make([]string, len(args))
+		for i, arg := range args {
+			cmdArgs[i] = strings.ReplaceAll(arg, "{}", filename)
+			replaced = replaced || cmdArgs[i] != arg
+		}
+		if !replaced {
+			cmdArgs = append(cmdArgs, filename)
+		}
+
+		out, err := exec.CommandContext(ctx, name, cmdArgs...).CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("exec %s: %w\n%s", name, err, string(out))
+		}
+		log.Debug("Executed", "command", name, "file", filename, "output", string(out))
+		return []string{filename}, nil
+	})
+}
@@ -0,0 +1,81 @@
+package tt
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPostProcessors(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "video.mp4")
+	if err := os.WriteFile(filename, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	moved := filepath.Join(dir, "moved")
+	opts := (&DownloadOpt{
+		PostProcessors: []PostProcessor{MoveTo(moved), HashSHA256()},
+		Log:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}).WithDefaults()
+	filenames, err := opts.postProcess(context.Background(), &Post{}, []string{filename})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{filepath.Join(moved, "video.mp4"), filepath.Join(moved, "video.mp4.sha256")}
+	if !slices.Equal(filenames, want) {
+		t.Fatalf("got %v, want %v", filenames, want)
+	}
+	sum, err := os.ReadFile(want[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(sum), "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  video.mp4\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("%s is not moved", filename)
+	}
+}
+
+func TestPackAlbum(t *testing.T) {
+	dir := t.TempDir()
+	filenames := []string{filepath.Join(dir, "user_id_1.jpg"), filepath.Join(dir, "user_id_2.jpg"), filepath.Join(dir, "user_id_music.mp3")}
+	for _, filename := range filenames {
+		if err := os.WriteFile(filename, []byte(filepath.Base(filename)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	post := &Post{Images: []string{"1", "2"}}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	result, err := PackAlbum("cbz")(context.Background(), post, filenames, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "user_id.cbz"), filenames[2]}; !slices.Equal(result, want) {
+		t.Fatalf("got %v, want %v", result, want)
+	}
+
+	archive, err := zip.OpenReader(result[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	names := []string{}
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	if want := []string{"001.jpg", "002.jpg", "music.mp3"}; !slices.Equal(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+	if _, err := os.Stat(filenames[0]); !os.IsNotExist(err) {
+		t.Errorf("%s is not removed", filenames[0])
+	}
+}
@@ -0,0 +1,89 @@
+package tt
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrWatermarked is returned when only a watermarked source of the video is available and QualityPolicy disallows it.
+var ErrWatermarked = errors.New("only a watermarked source is available")
+
+// Variant of the video source.
+type Variant string
+
+const (
+	VariantHD          Variant = "hd"
+	VariantSD          Variant = "sd"
+	VariantWatermarked Variant = "wm"
+)
+
+// QualityPolicy chooses the source of videos, see DownloadOpt.Quality.
+type QualityPolicy struct {
+	// Prefer variants in this order, the ones that aren't listed are never used. Default: hd, sd, wm.
+	Prefer []Variant
+	// AllowWatermark to use VariantWatermarked, otherwise ErrWatermarked is returned if it's the only one left.
+	// It's appended to Prefer if it's not there.
+	AllowWatermark bool
+}
+
+// DownloadedFile tells where a file of the post came from, see DownloadOpt.OnDownloaded.
+type DownloadedFile struct {
+	Filename string
+	URL      string
+	Asset    Asset
+	// Variant of videos, it's empty for the rest.
+	Variant Variant
+}
+
+// defaultQuality is the quiet fallback of Post.ContentUrls.
+func defaultQuality(sd bool) *QualityPolicy {
+	if sd {
+		return &QualityPolicy{Prefer: []Variant{VariantSD}, AllowWatermark: true}
+	}
+	return &QualityPolicy{Prefer: []Variant{VariantHD, VariantSD}, AllowWatermark: true}
+}
+
+// Choose the source of the video post.
+func (policy *QualityPolicy) Choose(post *Post) (url string, variant Variant, err error) {
+	prefer := policy.Prefer
+	if len(prefer) == 0 {
+		prefer = []Variant{VariantHD, VariantSD, VariantWatermarked}
+	}
+
+	sources := map[Variant]string{VariantHD: post.Hdplay, VariantSD: post.Play, VariantWatermarked: post.Wmplay}
+	watermarked := false
+	for _, variant := range append(slices.Clip(prefer), VariantWatermarked) {
+		url, ok := sources[variant]
+		if !ok {
+			return "", "", fmt.Errorf("unknown variant %q", variant)
+		}
+		if url == "" {
+			continue
+		}
+		if variant == VariantWatermarked && !policy.AllowWatermark {
+			watermarked = true
+			continue
+		}
+		return url, variant, nil
+	}
+
+	if watermarked {
+		return "", "", fmt.Errorf("post %s: %w", post.ID(), ErrWatermarked)
+	}
+	return "", "", fmt.Errorf("post %s: no source of %v", post.ID(), prefer)
+}
+
+// withoutHD is the policy for FallbackToSD.
+func (policy *QualityPolicy) withoutHD() *QualityPolicy {
+	result := &QualityPolicy{AllowWatermark: policy.AllowWatermark}
+	for _, variant := range policy.Prefer {
+		if variant != VariantHD {
+			result.Prefer = append(result.Prefer, variant)
+		}
+	}
+	if len(result.Prefer) == 0 {
+		result.Prefer = []Variant{VariantSD}
+	}
+	return result
+}
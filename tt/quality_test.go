@@ -0,0 +1,27 @@
+package tt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQualityPolicy(t *testing.T) {
+	post := &Post{Id: "1", Play: "sd", Wmplay: "wm"}
+
+	tests := []struct {
+		policy  QualityPolicy
+		variant Variant
+		err     error
+	}{
+		{QualityPolicy{}, VariantSD, nil},
+		{QualityPolicy{Prefer: []Variant{VariantHD}}, "", ErrWatermarked},
+		{QualityPolicy{Prefer: []Variant{VariantHD}, AllowWatermark: true}, VariantWatermarked, nil},
+		{QualityPolicy{Prefer: []Variant{VariantWatermarked, VariantSD}, AllowWatermark: true}, VariantWatermarked, nil},
+	}
+	for i, test := range tests {
+		_, variant, err := test.policy.Choose(post)
+		if variant != test.variant || !errors.Is(err, test.err) {
+			t.Errorf("%d: got %q, %v, want %q, %v", i, variant, err, test.variant, test.err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package tt
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter paces API requests of a Client, it could be shared between clients.
+type RateLimiter interface {
+	// Wait blocks until a request is allowed, or returns ctx.Err() once ctx is done.
+	Wait(ctx context.Context) error
+	// Report is called after every API response, limited is true when the API answered with a rate-limit error.
+	Report(limited bool)
+}
+
+// NoRateLimit lets every request through.
+var NoRateLimit RateLimiter = noRateLimit{}
+
+type noRateLimit struct{}
+
+func (noRateLimit) Wait(ctx context.Context) error { return ctx.Err() }
+func (noRateLimit) Report(bool)                    {}
+
+// TokenBucket is the default RateLimiter. It allows Burst requests at once and refills at RPS requests per second.
+// Once the API reports a rate limit it halves the rate (down to 1/16 of RPS) and empties the bucket,
+// every successful request afterwards brings back a tenth of RPS.
+type TokenBucket struct {
+	mu      sync.Mutex
+	rps     float64
+	current float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+}
+
+var _ RateLimiter = &TokenBucket{}
+
+// NewTokenBucket allows rps (> 0) requests per second on average and up to burst requests at once.
+func NewTokenBucket(rps float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rps:     rps,
+		current: rps,
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		last:    time.Now(),
+	}
+}
+
+// Every is NewTokenBucket allowing one request per interval.
+func Every(interval time.Duration) *TokenBucket {
+	return NewTokenBucket(float64(time.Second)/float64(interval), 1)
+}
+
+func (bucket *TokenBucket) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for {
+		wait := bucket.take()
+		if wait == 0 {
+			return nil
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func (bucket *TokenBucket) Report(limited bool) {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	bucket.refill()
+	if limited {
+		bucket.current = math.Max(bucket.current/2, bucket.rps/16)
+		bucket.tokens = 0
+	} else if bucket.current < bucket.rps {
+		bucket.current = math.Min(bucket.current+bucket.rps/10, bucket.rps)
+	}
+}
+
+// RPS is the current rate, it's lower than the configured one while backing off.
+func (bucket *TokenBucket) RPS() float64 {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+	return bucket.current
+}
+
+// take a token, or return how long to wait for one.
+func (bucket *TokenBucket) take() time.Duration {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	bucket.refill()
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0
+	}
+	return time.Duration((1 - bucket.tokens) / bucket.current * float64(time.Second))
+}
+
+func (bucket *TokenBucket) refill() {
+	now := time.Now()
+	bucket.tokens = math.Min(bucket.tokens+now.Sub(bucket.last).Seconds()*bucket.current, bucket.burst)
+	bucket.last = now
+}
+
+var (
+	defaultRateLimiter     RateLimiter
+	defaultRateLimiterOnce sync.Once
+)
+
+// timeoutRateLimiter is the RateLimiter equivalent of a Timeout between requests.
+func timeoutRateLimiter(timeout time.Duration) RateLimiter {
+	if timeout <= 0 {
+		return NoRateLimit
+	}
+	return Every(timeout)
+}
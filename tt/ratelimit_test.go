@@ -0,0 +1,39 @@
+package tt
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := NewTokenBucket(50, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := bucket.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("burst of 2 let 3 requests through in %s", elapsed)
+	}
+
+	bucket.Report(true)
+	if bucket.RPS() != 25 {
+		t.Errorf("rate after backoff: %f", bucket.RPS())
+	}
+	for i := 0; i < 10; i++ {
+		bucket.Report(false)
+	}
+	if bucket.RPS() != 50 {
+		t.Errorf("rate after recovery: %f", bucket.RPS())
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := bucket.Wait(cancelled); err == nil {
+		t.Error("Wait ignores cancelled context")
+	}
+}
@@ -0,0 +1,92 @@
+package tt
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy retries failed API requests of a Client with exponential backoff and jitter.
+type RetryPolicy struct {
+	// MaxAttempts including the first one, 0 is set to DefaultRetryPolicy.MaxAttempts. To disable retries set it to 1.
+	MaxAttempts int
+	// BaseDelay before the first retry, it's multiplied by Multiplier for every next one.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts.
+	MaxDelay time.Duration
+	// Multiplier of the delay, defaults to 2.
+	Multiplier float64
+	// Jitter spreads delays randomly by this share, i.e. 0.2 gives delays in [0.8, 1.2] * delay.
+	// Defaults to DefaultRetryPolicy.Jitter, a negative one disables it.
+	Jitter float64
+	// Retryable decides which errors are worth another attempt, defaults to IsTransient.
+	Retryable func(err error) bool
+	// OnRetry is called before every retry, defaults to logging with Client.Log.
+	OnRetry func(method string, attempt int, delay time.Duration, err error)
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   time.Second * 2,
+	MaxDelay:    time.Second * 30,
+	Multiplier:  2,
+	Jitter:      0.2,
+}
+
+func (policy *RetryPolicy) WithDefaults() *RetryPolicy {
+	if policy == nil {
+		policy = &RetryPolicy{}
+	}
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if policy.BaseDelay == 0 {
+		policy.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if policy.MaxDelay == 0 {
+		policy.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	if policy.Multiplier == 0 {
+		policy.Multiplier = DefaultRetryPolicy.Multiplier
+	}
+	if policy.Jitter == 0 {
+		policy.Jitter = DefaultRetryPolicy.Jitter
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = IsTransient
+	}
+	return policy
+}
+
+// Delay before the given retry, counting from 1.
+func (policy *RetryPolicy) Delay(retry int) time.Duration {
+	delay := float64(policy.BaseDelay) * math.Pow(policy.Multiplier, float64(retry-1))
+	delay = math.Min(delay, float64(policy.MaxDelay))
+	if policy.Jitter > 0 {
+		delay *= 1 + policy.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
+
+// retry calls do until it succeeds, fails permanently, runs out of attempts or ctx is done.
+func (c *Client) retry(ctx context.Context, method string, do func() error) error {
+	policy := c.retryPolicy()
+	onRetry := policy.OnRetry
+	if onRetry == nil {
+		onRetry = func(method string, attempt int, delay time.Duration, err error) {
+			c.log().Warn("Request failed, retrying...", "method", method, "attempt", attempt, "delay", delay, "err", err)
+		}
+	}
+
+	err := do()
+	for attempt := 1; attempt < policy.MaxAttempts && err != nil && policy.Retryable(err); attempt++ {
+		delay := policy.Delay(attempt)
+		onRetry(method, attempt, delay, err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+		err = do()
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package tt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	policy := (&RetryPolicy{}).WithDefaults()
+	delays := map[time.Duration]bool{}
+	for range 20 {
+		delay := policy.Delay(1)
+		if delay < time.Second*2*8/10 || delay > time.Second*2*12/10 {
+			t.Fatalf("got %v, want 2s ± 20%%", delay)
+		}
+		delays[delay] = true
+	}
+	if len(delays) == 1 {
+		t.Error("the default policy has no jitter")
+	}
+
+	policy = (&RetryPolicy{Jitter: -1}).WithDefaults()
+	if delay := policy.Delay(3); delay != time.Second*8 {
+		t.Errorf("got %v without jitter, want 8s", delay)
+	}
+	if delay := policy.Delay(10); delay != time.Second*30 {
+		t.Errorf("got %v, want MaxDelay", delay)
+	}
+}
@@ -0,0 +1,126 @@
+package tt
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scheduler runs file transfers in parallel, with a bounded number of transfers overall and per host.
+// Share one between Post.Download calls via DownloadOpt.Scheduler to bound the transfers across posts,
+// it replaces DownloadOpt.NoSync and DownloadOpt.Timeout for them.
+type Scheduler struct {
+	jobs    chan struct{}
+	perHost int
+
+	mu        sync.Mutex
+	hosts     map[string]chan struct{}
+	transfers map[*Transfer]struct{}
+}
+
+// Transfer is the progress of a single file, sizes are known only for the default DownloadWithContext.
+type Transfer struct {
+	URL      string
+	Filename string
+	Started  time.Time
+
+	size     atomic.Int64
+	complete atomic.Int64
+	done     atomic.Bool
+}
+
+// NewScheduler allows jobs transfers at once, and perHost of them to the same host, perHost <= 0 means no host limit.
+func NewScheduler(jobs int, perHost int) *Scheduler {
+	if jobs < 1 {
+		jobs = 1
+	}
+	return &Scheduler{
+		jobs:      make(chan struct{}, jobs),
+		perHost:   perHost,
+		hosts:     map[string]chan struct{}{},
+		transfers: map[*Transfer]struct{}{},
+	}
+}
+
+// Transfers that are running right now.
+func (scheduler *Scheduler) Transfers() []*Transfer {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	transfers := make([]*Transfer, 0, len(scheduler.transfers))
+	for transfer := range scheduler.transfers {
+		transfers = append(transfers, transfer)
+	}
+	return transfers
+}
+
+// Progress of the transfer in bytes, size is 0 while unknown.
+func (transfer *Transfer) Progress() (complete int64, size int64) {
+	return transfer.complete.Load(), transfer.size.Load()
+}
+
+func (transfer *Transfer) Done() bool {
+	return transfer.done.Load()
+}
+
+// run do once there's a free slot overall and for the host of rawURL, the transfer is reachable from ctx.
+func (scheduler *Scheduler) run(ctx context.Context, rawURL string, filename string, do func(ctx context.Context) error) error {
+	host := rawURL
+	if parsed, err := url.Parse(rawURL); err == nil {
+		host = parsed.Host
+	}
+
+	hostSlots := scheduler.hostSlots(host)
+	if hostSlots != nil {
+		select {
+		case hostSlots <- struct{}{}:
+			defer func() { <-hostSlots }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	select {
+	case scheduler.jobs <- struct{}{}:
+		defer func() { <-scheduler.jobs }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	transfer := &Transfer{URL: rawURL, Filename: filename, Started: time.Now()}
+	scheduler.mu.Lock()
+	scheduler.transfers[transfer] = struct{}{}
+	scheduler.mu.Unlock()
+	defer func() {
+		transfer.done.Store(true)
+		scheduler.mu.Lock()
+		delete(scheduler.transfers, transfer)
+		scheduler.mu.Unlock()
+	}()
+
+	return do(context.WithValue(ctx, transferKey{}, transfer))
+}
+
+func (scheduler *Scheduler) hostSlots(host string) chan struct{} {
+	if scheduler.perHost <= 0 {
+		return nil
+	}
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	slots, ok := scheduler.hosts[host]
+	if !ok {
+		slots = make(chan struct{}, scheduler.perHost)
+		scheduler.hosts[host] = slots
+	}
+	return slots
+}
+
+type transferKey struct{}
+
+// transferFromContext returns the transfer to report the progress to, if any.
+func transferFromContext(ctx context.Context) *Transfer {
+	transfer, _ := ctx.Value(transferKey{}).(*Transfer)
+	return transfer
+}
@@ -0,0 +1,69 @@
+package tt_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heilkit/tt/tt"
+)
+
+func TestSchedulerOffline(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	// Every image takes ~400ms, progress is reported every 200ms.
+	server.BytesPerSecond = 6000
+	album := server.Post("7301000000000000003")
+
+	for _, test := range []struct {
+		jobs, perHost, want int
+	}{
+		{jobs: 2, perHost: 0, want: 2},
+		{jobs: 3, perHost: 1, want: 1},
+	} {
+		scheduler := tt.NewScheduler(test.jobs, test.perHost)
+		opt := &tt.DownloadOpt{Directory: t.TempDir(), Scheduler: scheduler}
+
+		done := make(chan struct{})
+		running, progressed := 0, false
+		go func() {
+			defer close(done)
+			for stop := time.After(time.Second * 5); ; {
+				transfers := scheduler.Transfers()
+				running = max(running, len(transfers))
+				for _, transfer := range transfers {
+					if complete, size := transfer.Progress(); complete > 0 && size > 0 {
+						progressed = true
+					}
+				}
+				select {
+				case <-stop:
+					return
+				case <-time.After(time.Millisecond * 5):
+				}
+				if running != 0 && len(transfers) == 0 {
+					return
+				}
+			}
+		}()
+
+		filenames, err := client.DownloadPost(album, opt)
+		<-done
+		if err != nil {
+			t.Fatal(err)
+		}
+		if running != test.want {
+			t.Errorf("jobs %d, per host %d: got %d transfers at once, want %d", test.jobs, test.perHost, running, test.want)
+		}
+		if !progressed {
+			t.Errorf("jobs %d, per host %d: no progress is reported", test.jobs, test.perHost)
+		}
+		for i, filename := range filenames {
+			if !strings.HasSuffix(filename, fmt.Sprintf("_%d.jpg", i+1)) {
+				t.Errorf("got %v, want the order of the images", filenames)
+				break
+			}
+		}
+	}
+}
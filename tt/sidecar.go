@@ -0,0 +1,65 @@
+package tt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// InfoJSON is the content of <file>.info.json sidecars, see DownloadOpt.WriteInfoJSON.
+type InfoJSON struct {
+	Post         *Post     `json:"post"`
+	URL          string    `json:"url"`
+	ContentURLs  []string  `json:"content_urls"`
+	Files        []string  `json:"files"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// writeSidecars next to the downloaded files of the post, urls are the ones they were downloaded from.
+func (opts *DownloadOpt) writeSidecars(post *Post, urls []string, filenames []string) error {
+	if len(filenames) == 0 {
+		return nil
+	}
+	// Post processors could have moved the files.
+	base := path.Join(path.Dir(filenames[0]), path.Base(opts.baseFilename(post)))
+
+	if opts.WriteInfoJSON {
+		info := InfoJSON{Post: post, URL: post.URL(), ContentURLs: urls, Files: filenames, DownloadedAt: time.Now()}
+		buffer, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return fmt.Errorf("info json: %w", err)
+		}
+		if err := os.WriteFile(base+".info.json", buffer, 0644); err != nil {
+			return fmt.Errorf("info json: %w", err)
+		}
+	}
+	if opts.WriteDescription {
+		if err := os.WriteFile(base+".description", []byte(post.Title), 0644); err != nil {
+			return fmt.Errorf("description: %w", err)
+		}
+	}
+	if opts.WriteCaption {
+		if err := os.WriteFile(base+".txt", []byte(post.caption()), 0644); err != nil {
+			return fmt.Errorf("caption: %w", err)
+		}
+	}
+	return nil
+}
+
+// caption is a human-readable summary of the post.
+func (post Post) caption() string {
+	lines := []string{}
+	if post.Title != "" {
+		lines = append(lines, post.Title, "")
+	}
+	lines = append(lines, fmt.Sprintf("@%s (%s), %s", post.Author.UniqueId, post.Author.Nickname,
+		time.Unix(post.CreateTime, 0).Format(time.DateTime)))
+	if post.MusicInfo.Title != "" {
+		lines = append(lines, fmt.Sprintf("Music: %s - %s", post.MusicInfo.Author, post.MusicInfo.Title))
+	}
+	lines = append(lines, post.URL())
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,65 @@
+package tt_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heilkit/tt/tt"
+)
+
+func TestSidecarsOffline(t *testing.T) {
+	server := newServer(t)
+	client := server.Client()
+	dir := t.TempDir()
+	moved := filepath.Join(dir, "moved")
+	opt := &tt.DownloadOpt{Directory: dir, Timeout: time.Nanosecond, WriteInfoJSON: true, WriteDescription: true, WriteCaption: true,
+		PostProcessors: []tt.PostProcessor{tt.MoveTo(moved)}}
+
+	// The sidecars follow the moved video.
+	post := server.Post("7301000000000000004")
+	filenames, err := client.DownloadPost(post, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := strings.TrimSuffix(filenames[0], ".mp4")
+	if filepath.Dir(base) != moved {
+		t.Fatalf("got %v, want the video in %s", filenames, moved)
+	}
+
+	var info tt.InfoJSON
+	if data, err := os.ReadFile(base + ".info.json"); err != nil || json.Unmarshal(data, &info) != nil {
+		t.Fatalf("bad info json: %v", err)
+	}
+	if info.Post.ID() != post.ID() || info.URL != post.URL() || !slices.Equal(info.Files, filenames) || len(info.ContentURLs) != 1 {
+		t.Errorf("got %+v", info)
+	}
+	if data, err := os.ReadFile(base + ".description"); err != nil || string(data) != "the newest one #fyp" {
+		t.Errorf("got description %q: %v", data, err)
+	}
+	caption, err := os.ReadFile(base + ".txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(caption), "the newest one #fyp\n\n@losertron (Loser Tron), ") || !strings.HasSuffix(string(caption), post.URL()+"\n") {
+		t.Errorf("got caption %q", caption)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("got %v left in %s: %v", entries, dir, err)
+	}
+
+	// The sidecars of an album are named after the album, not its first image.
+	album := &tt.DownloadOpt{Directory: t.TempDir(), Timeout: time.Nanosecond, WriteDescription: true,
+		PostProcessors: []tt.PostProcessor{tt.PackAlbum("zip")}}
+	filenames, err = client.DownloadPost(server.Post("7301000000000000003"), album)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(strings.TrimSuffix(filenames[0], ".zip") + ".description"); err != nil || string(data) != "a photo dump" {
+		t.Errorf("got description %q next to %v: %v", data, filenames, err)
+	}
+}
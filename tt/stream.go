@@ -0,0 +1,137 @@
+package tt
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// FeedProgress of a streamed user feed, it's safe for concurrent use.
+type FeedProgress struct {
+	mu       sync.Mutex
+	expected int
+	final    bool
+	err      error
+}
+
+// Expected number of posts: the ones found on the pages fetched so far, final once every page is fetched.
+func (progress *FeedProgress) Expected() (count int, final bool) {
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	return progress.expected, progress.final
+}
+
+// Err that stopped fetching the pages, it's also passed to FeedOpt.OnError.
+func (progress *FeedProgress) Err() error {
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	return progress.err
+}
+
+func (progress *FeedProgress) add(count int) {
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	progress.expected += count
+}
+
+func (progress *FeedProgress) finish(err error) {
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	progress.final = true
+	progress.err = err
+}
+
+// GetUserFeedStream emits posts newest-first, page by page as soon as they arrive, unlike GetUserFeed,
+// which fetches every page before emitting anything. FeedOpt.OldestFirst brings back the order of GetUserFeed,
+// at the cost of buffering the whole feed. Nothing is kept in memory otherwise, and the channel is closed once
+// the feed ends, fails or ctx is done.
+func GetUserFeedStream(uniqueID string, opts ...FeedOpt) (chan Post, *FeedProgress, error) {
+	return DefaultClient.GetUserFeedStreamContext(context.Background(), uniqueID, opts...)
+}
+
+func GetUserFeedStreamContext(ctx context.Context, uniqueID string, opts ...FeedOpt) (chan Post, *FeedProgress, error) {
+	return DefaultClient.GetUserFeedStreamContext(ctx, uniqueID, opts...)
+}
+
+func (c *Client) GetUserFeedStream(uniqueID string, opts ...FeedOpt) (chan Post, *FeedProgress, error) {
+	return c.GetUserFeedStreamContext(context.Background(), uniqueID, opts...)
+}
+
+func (c *Client) GetUserFeedStreamContext(ctx context.Context, uniqueID string, opts ...FeedOpt) (chan Post, *FeedProgress, error) {
+	var opt *FeedOpt = nil
+	if len(opts) != 0 {
+		opt = &opts[0]
+	}
+	opt = opt.Defaults()
+
+	checkpoint, err := loadFeedCheckpoint(uniqueID, opt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	progress := &FeedProgress{}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				// It's ok to panic in onError to interrupt the loop, the same way as for GetUserFeed.
+			}
+		}()
+
+		defer close(opt.ReturnChan)
+		if opt.OldestFirst {
+			c.streamOldestFirst(ctx, uniqueID, opt, checkpoint, progress)
+		} else {
+			c.streamNewestFirst(ctx, uniqueID, opt, checkpoint, progress)
+		}
+	}()
+
+	return opt.ReturnChan, progress, nil
+}
+
+func (c *Client) streamNewestFirst(ctx context.Context, uniqueID string, opt *FeedOpt, checkpoint *feedCheckpoint, progress *FeedProgress) {
+	send := func(post Post, err error) bool {
+		return c.sendPost(ctx, post, err, opt)
+	}
+
+	cursor, pending := checkpoint.cursor()
+	progress.add(len(pending))
+	c.resolveHDAll(ctx, pending, opt, send)
+	if ctx.Err() != nil {
+		progress.finish(ctx.Err())
+		return
+	}
+
+	err := c.walkUserFeed(ctx, uniqueID, cursor, opt, checkpoint, func(posts []Post, next string) error {
+		progress.add(len(posts))
+		if next == "" {
+			progress.finish(nil)
+		}
+		checkpoint.fetched(next, posts)
+		c.resolveHDAll(ctx, posts, opt, send)
+		return ctx.Err()
+	})
+	if err != nil {
+		progress.finish(err)
+		if ctx.Err() == nil {
+			opt.OnError(err)
+		}
+	}
+}
+
+func (c *Client) streamOldestFirst(ctx context.Context, uniqueID string, opt *FeedOpt, checkpoint *feedCheckpoint, progress *FeedProgress) {
+	posts, err := c.userFeedUntilInternal(ctx, uniqueID, opt, checkpoint)
+	progress.add(len(posts))
+	progress.finish(err)
+	if err != nil {
+		if ctx.Err() == nil {
+			opt.OnError(err)
+		}
+		return
+	}
+
+	checkpoint.walked()
+	slices.Reverse(posts)
+	c.resolveHDAll(ctx, posts, opt, func(post Post, err error) bool {
+		return c.sendPost(ctx, post, err, opt)
+	})
+}
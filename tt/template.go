@@ -0,0 +1,197 @@
+package tt
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FilenameTemplate parses a template for DownloadOpt.FilenameFormat,
+// i.e. "{author.unique_id}/{create_time:2006-01}/{id}_{title:.40}.{ext}".
+//
+// Fields are json names of Post fields, nested ones are joined with dots, and a few extra ones:
+//
+//	{id}     Post.ID()
+//	{ext}    "mp4" for videos, "jpg" for album images
+//	{index}  1-based index of the album image, "1" for videos. Album images get "_{index}" before the extension
+//	         if the template has no {index}, so they don't overwrite each other.
+//
+// A format goes after a colon: ".N" truncates the value to N characters, and create_time takes a Go time layout
+// (default: 2006-01-02). Characters that are illegal in filenames are replaced in the values, while "/" of the template
+// itself makes directories. "{{" and "}}" are literal braces. Errors are reported for unknown fields and formats.
+func FilenameTemplate(template string) (func(post *Post, i int) string, error) {
+	parts, err := parseTemplate(template)
+	if err != nil {
+		return nil, fmt.Errorf("filename template %q: %w", template, err)
+	}
+
+	return func(post *Post, i int) string {
+		builder := strings.Builder{}
+		for _, part := range parts {
+			builder.WriteString(part(post, i))
+		}
+		return builder.String()
+	}, nil
+}
+
+type templatePart func(post *Post, i int) string
+
+func parseTemplate(template string) ([]templatePart, error) {
+	parts := []templatePart{}
+	literal := strings.Builder{}
+	flush := func() {
+		if literal.Len() != 0 {
+			text := literal.String()
+			parts = append(parts, func(*Post, int) string { return text })
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(template); i++ {
+		switch {
+		case strings.HasPrefix(template[i:], "{{"), strings.HasPrefix(template[i:], "}}"):
+			literal.WriteByte(template[i])
+			i++
+		case template[i] == '}':
+			return nil, fmt.Errorf("unexpected '}' at %d", i)
+		case template[i] == '{':
+			end := strings.IndexByte(template[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed '{' at %d", i)
+			}
+			part, err := parseField(template[i+1 : i+end])
+			if err != nil {
+				return nil, err
+			}
+			flush()
+			parts = append(parts, part)
+			i += end
+		default:
+			literal.WriteByte(template[i])
+		}
+	}
+	flush()
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("empty template")
+	}
+	return parts, nil
+}
+
+func parseField(field string) (templatePart, error) {
+	name, format, hasFormat := strings.Cut(field, ":")
+	truncate := -1
+	if hasFormat && strings.HasPrefix(format, ".") {
+		n, err := strconv.Atoi(format[1:])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("bad truncation %q of {%s}", format, field)
+		}
+		truncate, format, hasFormat = n, "", false
+	}
+
+	value, err := fieldValue(name, format, hasFormat)
+	if err != nil {
+		return nil, err
+	}
+	return func(post *Post, i int) string {
+		// Truncation could leave ".." of a longer value, so it goes first.
+		return sanitizeFilename(truncateRunes(value(post, i), truncate))
+	}, nil
+}
+
+func fieldValue(name string, format string, hasFormat bool) (templatePart, error) {
+	if hasFormat && name != "create_time" {
+		return nil, fmt.Errorf("{%s} doesn't take format %q", name, format)
+	}
+
+	switch name {
+	case "id":
+		return func(post *Post, i int) string { return post.ID() }, nil
+	case "ext":
+		return func(post *Post, i int) string { return post.extension() }, nil
+	case "index":
+		return func(post *Post, i int) string { return strconv.Itoa(i + 1) }, nil
+	case "create_time":
+		if !hasFormat {
+			format = time.DateOnly
+		}
+		return func(post *Post, i int) string { return time.Unix(post.CreateTime, 0).Format(format) }, nil
+	}
+
+	index, err := jsonFieldIndex(reflect.TypeOf(Post{}), name)
+	if err != nil {
+		return nil, err
+	}
+	return func(post *Post, i int) string {
+		value := reflect.ValueOf(post).Elem().FieldByIndex(index)
+		switch value.Kind() {
+		case reflect.String:
+			return value.String()
+		case reflect.Bool:
+			return strconv.FormatBool(value.Bool())
+		case reflect.Float32, reflect.Float64:
+			return strconv.FormatFloat(value.Float(), 'f', -1, 64)
+		default:
+			return strconv.FormatInt(value.Int(), 10)
+		}
+	}, nil
+}
+
+// jsonFieldIndex finds a scalar field by the dot separated path of json names.
+func jsonFieldIndex(typ reflect.Type, path string) ([]int, error) {
+	index := []int{}
+	for _, name := range strings.Split(path, ".") {
+		if typ.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("unknown field {%s}", path)
+		}
+		found := false
+		for i := 0; i < typ.NumField(); i++ {
+			if tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ","); tag == name {
+				index = append(index, i)
+				typ = typ.Field(i).Type
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown field {%s}", path)
+		}
+	}
+
+	switch typ.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return index, nil
+	}
+	return nil, fmt.Errorf("field {%s} is not a string or a number", path)
+}
+
+// sanitizeFilename replaces characters that are illegal in filenames on any of the common systems.
+func sanitizeFilename(value string) string {
+	value = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			return ' '
+		case r < ' ' || r == 0x7f:
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, value)
+
+	if value == "." || value == ".." {
+		return "_"
+	}
+	return value
+}
+
+func truncateRunes(value string, n int) string {
+	if n < 0 || utf8.RuneCountInString(value) <= n {
+		return value
+	}
+	return string([]rune(value)[:n])
+}
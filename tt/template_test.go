@@ -0,0 +1,38 @@
+package tt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilenameTemplate(t *testing.T) {
+	post := &Post{VideoId: "7179438804418268417", Title: "a/b: c?\nd", CreateTime: time.Date(2022, 12, 21, 12, 0, 0, 0, time.Local).Unix()}
+	post.Author.UniqueId = "canthinky"
+
+	format, err := FilenameTemplate("{author.unique_id}/{create_time:2006-01}/{id}_{title:.6}_{play_count}.{ext} {{x}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := format(post, 0), "canthinky/2022-12/7179438804418268417_a_b_ c_0.mp4 {x}"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	post.Images = []string{"1", "2"}
+	format, _ = FilenameTemplate("{id}_{index}.{ext}")
+	if got, want := format(post, 1), "7179438804418268417_2.jpg"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// A truncated value doesn't leave the directory.
+	post.Title = "..evil"
+	format, _ = FilenameTemplate("{title:.2}/{id}.{ext}")
+	if got, want := format(post, 0), "_/7179438804418268417.jpg"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, template := range []string{"", "{nope}", "{images}", "{title:2006}", "{title:.x}", "{id", "id}"} {
+		if _, err := FilenameTemplate(template); err == nil {
+			t.Errorf("template %q is accepted", template)
+		}
+	}
+}
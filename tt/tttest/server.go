@@ -0,0 +1,505 @@
+// Package tttest is a fake of the tikwm API and TikTok CDN for offline tests of tt.
+//
+//	server := tttest.NewServer()
+//	defer server.Close()
+//	if err := server.LoadFixtures("testdata"); err != nil { ... }
+//	client := server.Client()
+//	posts, err := client.GetUserFeedAwait("losertron")
+//
+// It answers "" (GetPost), "user/posts", "user/info" and "user/search", and serves the content of the posts under /cdn/.
+// Responses could be scripted per method with Script, i.e. to fail a few requests or to answer with a rate limit.
+package tttest
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"github.com/cavaliergopher/grab/v3"
+	"github.com/heilkit/tt/tt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Methods of the API the server answers.
+const (
+	MethodPost       = ""
+	MethodUserFeed   = "user/posts"
+	MethodUserInfo   = "user/info"
+	MethodUserSearch = "user/search"
+)
+
+// Response is a scripted answer to a request.
+type Response struct {
+	// Status of the HTTP response, default: 200.
+	Status int
+	// Code and Msg of the tikwm response, code 0 answers as usual.
+	Code int
+	Msg  string
+	// Body replaces the whole response if it's set, i.e. to send broken JSON.
+	Body string
+	// Delay before answering.
+	Delay time.Duration
+}
+
+// RateLimited is the answer tikwm gives to the clients that are too fast.
+func RateLimited() Response {
+	return Response{Code: -1, Msg: "Free Api Limit: 1 request/second."}
+}
+
+// NotFound is the answer for deleted posts and users that don't exist.
+func NotFound() Response {
+	return Response{Code: -1, Msg: "Url parsing is failed! Please check url. Video does not exist"}
+}
+
+// Status is a failed HTTP response.
+func Status(status int) Response {
+	return Response{Status: status, Body: http.StatusText(status)}
+}
+
+// Server is the fake, its zero options answer immediately without limits.
+type Server struct {
+	*httptest.Server
+
+	// RequestsPerSecond answers with RateLimited to the requests that come faster, 0 means no limit.
+	RequestsPerSecond float64
+	// BytesPerSecond of the CDN transfers, 0 means no limit.
+	BytesPerSecond int
+
+	mu          sync.Mutex
+	posts       map[string]*tt.Post
+	users       map[string]*tt.UserDetail
+	files       map[string][]byte
+	scripts     map[string][]Response
+	hits        map[string]int
+	lastRequest time.Time
+}
+
+// NewServer starts a server, Close it once done.
+func NewServer() *Server {
+	server := &Server{
+		posts:   map[string]*tt.Post{},
+		users:   map[string]*tt.UserDetail{},
+		files:   map[string][]byte{},
+		scripts: map[string][]Response{},
+		hits:    map[string]int{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/", server.handleAPI)
+	mux.HandleFunc("/cdn/", server.handleCDN)
+	server.Server = httptest.NewServer(mux)
+	return server
+}
+
+// Client talks to the server without rate limits, with short retry delays and without logs.
+func (server *Server) Client() *tt.Client {
+	return &tt.Client{
+		URL:        server.URL + "/api",
+		HTTPClient: server.Server.Client(),
+		Limiter:    tt.NoRateLimit,
+		Retry:      &tt.RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond * 10},
+		Grab:       &grab.Client{HTTPClient: server.Server.Client(), UserAgent: "tttest"},
+		Log:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// AddPost makes the post available, its media is replaced with files served by the server: Play and Wmplay always,
+// Hdplay for videos, the images for albums, the cover, the music and the avatar. Sizes are set to the content ones.
+func (server *Server) AddPost(post tt.Post) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	id := post.ID()
+	if post.VideoId == "" {
+		post.VideoId = id
+	}
+	if post.IsAlbum() {
+		post.Images = slices.Clone(post.Images)
+		for i := range post.Images {
+			post.Images[i] = server.addFile(id, fmt.Sprintf("image_%d.jpg", i+1))
+		}
+		post.Hdplay, post.HdSize = "", 0
+	} else {
+		post.Hdplay = server.addFile(id, "hd.mp4")
+		post.HdSize = int64(len(server.files[post.Hdplay]))
+	}
+	post.Play = server.addFile(id, "play.mp4")
+	post.Size = int64(len(server.files[post.Play]))
+	post.Wmplay = server.addFile(id, "wm.mp4")
+	post.WmSize = int64(len(server.files[post.Wmplay]))
+	post.Cover = server.addFile(id, "cover.jpg")
+	post.OriginCover = server.addFile(id, "origin_cover.jpg")
+	post.Music = server.addFile(id, "music.mp3")
+	post.MusicInfo.Play = post.Music
+	post.Author.Avatar = server.addFile(post.Author.UniqueId, "avatar.jpg")
+
+	server.posts[id] = &post
+}
+
+// addFile with deterministic content, it returns the URL of the file.
+func (server *Server) addFile(id string, name string) string {
+	path := fmt.Sprintf("/cdn/%s/%s", id, name)
+	server.files[path] = bytes.Repeat([]byte(path+"\n"), 64)
+	return server.URL + path
+}
+
+// File is the content served at the URL of the server, nil if there's none.
+func (server *Server) File(url string) []byte {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.files[strings.TrimPrefix(url, server.URL)]
+}
+
+// RemoveFile makes the CDN answer 404 for the URL.
+func (server *Server) RemoveFile(url string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	delete(server.files, strings.TrimPrefix(url, server.URL))
+}
+
+// Post as the server has it, with its media replaced.
+func (server *Server) Post(id string) *tt.Post {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if post, ok := server.posts[id]; ok {
+		copied := *post
+		return &copied
+	}
+	return nil
+}
+
+// AddUser makes user/info answer for the user, and user/search find it.
+func (server *Server) AddUser(user tt.UserDetail) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.users[user.User.UniqueId] = &user
+}
+
+// LoadFixtures reads recorded tikwm responses from the *.json files of the directory, see LoadFixture.
+func (server *Server) LoadFixtures(dir string) error {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		if err := server.LoadFixture(filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFixture reads a recorded response of "", user/posts or user/info: its posts are added with AddPost,
+// and its user with AddUser.
+func (server *Server) LoadFixture(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return fmt.Errorf("fixture %s: %w", filename, err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Data, &fields); err != nil {
+		return fmt.Errorf("fixture %s: %w", filename, err)
+	}
+	switch {
+	case fields["videos"] != nil:
+		var feed tt.UserFeed
+		if err := json.Unmarshal(resp.Data, &feed); err != nil {
+			return fmt.Errorf("fixture %s: %w", filename, err)
+		}
+		for _, post := range feed.Videos {
+			server.AddPost(post)
+		}
+	case fields["user"] != nil:
+		var user tt.UserDetail
+		if err := json.Unmarshal(resp.Data, &user); err != nil {
+			return fmt.Errorf("fixture %s: %w", filename, err)
+		}
+		server.AddUser(user)
+	default:
+		var post tt.Post
+		if err := json.Unmarshal(resp.Data, &post); err != nil {
+			return fmt.Errorf("fixture %s: %w", filename, err)
+		}
+		server.AddPost(post)
+	}
+	return nil
+}
+
+// Script queues responses for the next requests of the method, after them it answers as usual.
+// The method "cdn" scripts file transfers, only Status and Delay of the responses apply to them.
+func (server *Server) Script(method string, responses ...Response) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.scripts[method] = append(server.scripts[method], responses...)
+}
+
+// Hits is the number of requests of the method so far, CDN requests are counted as "cdn".
+func (server *Server) Hits(method string) int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.hits[method]
+}
+
+func (server *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	query := r.URL.Query()
+
+	server.mu.Lock()
+	server.hits[method]++
+	response, scripted := Response{}, false
+	if script := server.scripts[method]; len(script) != 0 {
+		response, scripted = script[0], true
+		server.scripts[method] = script[1:]
+	}
+	now := time.Now()
+	if !scripted && server.RequestsPerSecond > 0 &&
+		now.Sub(server.lastRequest) < time.Duration(float64(time.Second)/server.RequestsPerSecond) {
+		response, scripted = RateLimited(), true
+	}
+	server.lastRequest = now
+	server.mu.Unlock()
+
+	if response.Delay != 0 {
+		select {
+		case <-time.After(response.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if scripted && (response.Code != 0 || response.Body != "" || response.Status != 0) {
+		writeResponse(w, response, nil)
+		return
+	}
+
+	var data any
+	switch method {
+	case MethodPost:
+		if post := server.post(query.Get("url"), query.Get("hd") == "1"); post != nil {
+			data = post
+		}
+	case MethodUserFeed:
+		if feed := server.userFeed(query); feed != nil {
+			data = feed
+		}
+	case MethodUserInfo:
+		if user := server.userInfo(query.Get("unique_id")); user != nil {
+			data = user
+		}
+	case MethodUserSearch:
+		data = server.userSearch(query)
+	default:
+		writeResponse(w, Status(http.StatusNotFound), nil)
+		return
+	}
+	if data == nil {
+		writeResponse(w, NotFound(), nil)
+		return
+	}
+	writeResponse(w, Response{}, data)
+}
+
+func writeResponse(w http.ResponseWriter, response Response, data any) {
+	status := response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if response.Body != "" {
+		_, _ = w.Write([]byte(response.Body))
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"code":           response.Code,
+		"msg":            cmp.Or(response.Msg, "success"),
+		"processed_time": 0.01,
+		"data":           data,
+	})
+}
+
+var postID = regexp.MustCompile(`(\d{6,})\D*$`)
+
+// post by its id or URL, without HD unless hd.
+func (server *Server) post(url string, hd bool) *tt.Post {
+	id := url
+	if match := postID.FindStringSubmatch(url); match != nil {
+		id = match[1]
+	}
+	post := server.Post(id)
+	if post != nil && !hd {
+		post.Hdplay, post.HdSize = "", 0
+	}
+	return post
+}
+
+// userFeed pages the posts of the user from the newest, the cursor is the offset.
+func (server *Server) userFeed(query map[string][]string) *tt.UserFeed {
+	get := func(key string) string {
+		if values := query[key]; len(values) != 0 {
+			return values[0]
+		}
+		return ""
+	}
+	uniqueID, userID := get("unique_id"), get("user_id")
+	count, err := strconv.Atoi(get("count"))
+	if err != nil || count <= 0 {
+		count = 10
+	}
+	offset, _ := strconv.Atoi(get("cursor"))
+
+	server.mu.Lock()
+	posts := []tt.Post{}
+	for _, post := range server.posts {
+		if post.Author.UniqueId == uniqueID && uniqueID != "" || post.Author.Id == userID && userID != "" {
+			// Feeds have no HD, it's resolved by GetPost.
+			copied := *post
+			copied.Hdplay, copied.HdSize = "", 0
+			posts = append(posts, copied)
+		}
+	}
+	_, userKnown := server.users[uniqueID]
+	server.mu.Unlock()
+	if len(posts) == 0 && !userKnown {
+		return nil
+	}
+
+	slices.SortFunc(posts, func(a, b tt.Post) int {
+		if a.CreateTime != b.CreateTime {
+			return int(b.CreateTime - a.CreateTime)
+		}
+		return strings.Compare(b.ID(), a.ID())
+	})
+	offset = min(max(offset, 0), len(posts))
+	end := min(offset+count, len(posts))
+	return &tt.UserFeed{Videos: posts[offset:end], Cursor: strconv.Itoa(end), HasMore: end < len(posts)}
+}
+
+func (server *Server) userInfo(uniqueID string) *tt.UserDetail {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.users[uniqueID]
+}
+
+// userSearch finds the users by a part of their unique id or nickname, the cursor is the offset.
+func (server *Server) userSearch(query map[string][]string) map[string]any {
+	get := func(key string) string {
+		if values := query[key]; len(values) != 0 {
+			return values[0]
+		}
+		return ""
+	}
+	keyword := strings.ToLower(get("keywords"))
+	count, err := strconv.Atoi(get("count"))
+	if err != nil || count <= 0 {
+		count = 10
+	}
+	offset, _ := strconv.Atoi(get("cursor"))
+
+	server.mu.Lock()
+	found := []tt.SearchedUser{}
+	for _, user := range server.users {
+		if strings.Contains(strings.ToLower(user.User.UniqueId), keyword) || strings.Contains(strings.ToLower(user.User.Nickname), keyword) {
+			found = append(found, tt.SearchedUser{
+				Id:             user.User.Id,
+				UniqueId:       user.User.UniqueId,
+				Nickname:       user.User.Nickname,
+				Signature:      user.User.Signature,
+				Avatar:         user.User.AvatarThumb,
+				Verified:       user.User.Verified,
+				FollowerCount:  user.Stats.FollowerCount,
+				FollowingCount: user.Stats.FollowingCount,
+				VideoCount:     user.Stats.VideoCount,
+				TotalFavorited: user.Stats.HeartCount,
+			})
+		}
+	}
+	server.mu.Unlock()
+
+	slices.SortFunc(found, func(a, b tt.SearchedUser) int { return strings.Compare(a.UniqueId, b.UniqueId) })
+	offset = min(max(offset, 0), len(found))
+	end := min(offset+count, len(found))
+	list := []map[string]any{}
+	for _, user := range found[offset:end] {
+		list = append(list, map[string]any{"user_info": user})
+	}
+	return map[string]any{"user_list": list, "cursor": end, "hasMore": end < len(found)}
+}
+
+func (server *Server) handleCDN(w http.ResponseWriter, r *http.Request) {
+	server.mu.Lock()
+	server.hits["cdn"]++
+	content, ok := server.files[r.URL.Path]
+	response, scripted := Response{}, false
+	if script := server.scripts["cdn"]; len(script) != 0 {
+		response, scripted = script[0], true
+		server.scripts["cdn"] = script[1:]
+	}
+	bytesPerSecond := server.BytesPerSecond
+	server.mu.Unlock()
+
+	if response.Delay != 0 {
+		select {
+		case <-time.After(response.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if scripted && response.Status != 0 {
+		http.Error(w, response.Body, response.Status)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if bytesPerSecond > 0 {
+		w = &slowWriter{ResponseWriter: w, r: r, bytesPerSecond: bytesPerSecond}
+	}
+	http.ServeContent(w, r, filepath.Base(r.URL.Path), time.Time{}, bytes.NewReader(content))
+}
+
+// slowWriter writes at most bytesPerSecond, in chunks of a tenth of it.
+type slowWriter struct {
+	http.ResponseWriter
+	r              *http.Request
+	bytesPerSecond int
+}
+
+func (w *slowWriter) Write(data []byte) (int, error) {
+	chunk := max(w.bytesPerSecond/10, 1)
+	written := 0
+	for written < len(data) {
+		select {
+		case <-time.After(time.Second / 10):
+		case <-w.r.Context().Done():
+			return written, w.r.Context().Err()
+		}
+		n, err := w.ResponseWriter.Write(data[written:min(written+chunk, len(data))])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+	return written, nil
+}
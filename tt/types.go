@@ -1,5 +1,10 @@
 package tt
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type Post struct {
 	Id          string `json:"id"`
 	VideoId     string `json:"video_id"`
@@ -78,6 +83,34 @@ func (post Post) ContentUrls(hd ...bool) []string {
 	return urls
 }
 
+// expectedSize of the file at the content url, 0 if unknown.
+func (post Post) expectedSize(url string) int64 {
+	switch url {
+	case "":
+		return 0
+	case post.Hdplay:
+		return post.HdSize
+	case post.Play:
+		return post.Size
+	case post.Wmplay:
+		return post.WmSize
+	}
+	return 0
+}
+
+// URL of the post on TikTok.
+func (post Post) URL() string {
+	return fmt.Sprintf("https://www.tiktok.com/@%s/video/%s", post.Author.UniqueId, post.ID())
+}
+
+// extension of the content files.
+func (post Post) extension() string {
+	if post.IsVideo() {
+		return "mp4"
+	}
+	return "jpg"
+}
+
 // ID is the simplest way to get video's id
 func (post Post) ID() string {
 	if post.Id != "" {
@@ -92,6 +125,36 @@ type UserFeed struct {
 	HasMore bool   `json:"hasMore"`
 }
 
+// UserSearch is a page of SearchUsers, Users lists the results.
+type UserSearch struct {
+	UserList []struct {
+		UserInfo SearchedUser `json:"user_info"`
+	} `json:"user_list"`
+	Cursor  json.Number `json:"cursor"`
+	HasMore bool        `json:"hasMore"`
+}
+
+type SearchedUser struct {
+	Id             string `json:"uid"`
+	UniqueId       string `json:"unique_id"`
+	Nickname       string `json:"nickname"`
+	Signature      string `json:"signature"`
+	Avatar         string `json:"avatar"`
+	Verified       bool   `json:"verified"`
+	FollowerCount  int    `json:"follower_count"`
+	FollowingCount int    `json:"following_count"`
+	VideoCount     int    `json:"aweme_count"`
+	TotalFavorited int    `json:"total_favorited"`
+}
+
+func (search *UserSearch) Users() []SearchedUser {
+	users := make([]SearchedUser, 0, len(search.UserList))
+	for _, item := range search.UserList {
+		users = append(users, item.UserInfo)
+	}
+	return users
+}
+
 type UserDetail struct {
 	User struct {
 		Id                  string      `json:"id"`